@@ -1,45 +1,167 @@
 package main
 
 import (
-	"sync"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/fennet82/helga/internal/logger"
+	"github.com/fennet82/helga/internal/vars"
 	"github.com/fennet82/helga/pkg/config"
 	helga_errors "github.com/fennet82/helga/pkg/errors"
+	"github.com/fennet82/helga/pkg/models"
+	"github.com/spf13/cobra"
 )
 
-var helgaConfig config.Config = config.Config{}
+// exit codes of the cli
+const (
+	exitOK            = 0
+	exitError         = 1
+	exitConfigInvalid = 2
+	exitSyncFailed    = 3
+)
+
+type exitCodeError struct {
+	code int
+	err  error
+}
+
+func (e exitCodeError) Error() string {
+	return e.err.Error()
+}
 
 func main() {
-	var wg sync.WaitGroup
+	os.Exit(execute())
+}
 
+func execute() int {
 	defer finalize()
+
+	if err := newRootCmd().Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+
+		var exitErr exitCodeError
+		if errors.As(err, &exitErr) {
+			return exitErr.code
+		}
+
+		return exitError
+	}
+
+	return exitOK
+}
+
+func newRootCmd() *cobra.Command {
+	var confFilePath, logsFilePath string
+
+	root := &cobra.Command{
+		Use:           "helga",
+		Short:         "Keeps helm releases in kubernetes namespaces in sync with their chart sources",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		// flags override the environment variables and have to be applied before anything logs
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			if cmd.Flags().Changed("config") {
+				vars.HELGA_CONF_FILE_PATH = confFilePath
+			}
+
+			if cmd.Flags().Changed("log-file") {
+				vars.LOGS_FILE_PATH = logsFilePath
+			}
+
+			if vars.LOGS_FILE_PATH == "" {
+				return errors.New("log file path is not set, use --log-file or LOGS_FILE_PATH")
+			}
+
+			if vars.HELGA_CONF_FILE_PATH == "" {
+				return exitCodeError{code: exitConfigInvalid, err: errors.New("config path is not set, use --config or HELGA_CONF_FILE_PATH")}
+			}
+
+			return nil
+		},
+	}
+
+	root.PersistentFlags().StringVarP(&confFilePath, "config", "c", vars.HELGA_CONF_FILE_PATH, "config file, directory or comma separated list of files and globs (overrides HELGA_CONF_FILE_PATH)")
+	root.PersistentFlags().StringVar(&logsFilePath, "log-file", vars.LOGS_FILE_PATH, "log file path (overrides LOGS_FILE_PATH)")
+
+	runCmd := newRunCmd()
+
+	// running helga without a command keeps the daemon behaviour
+	root.RunE = runCmd.RunE
+
+	root.AddCommand(runCmd, newSyncOnceCmd(), newPlanCmd(), newValidateCmd(), newStatusCmd())
+
+	return root
+}
+
+// cancelled on SIGTERM/SIGINT, namespaces stop after their in-flight helm operations
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+}
+
+func loadConfig() (*config.Config, error) {
 	logger.GetLoggerInstance().Info("loading configuration...")
 
-	errs := helgaConfig.UnmarshalYAMLConfig()
+	c := &config.Config{}
+
+	// the errors are already logged while loading
+	errs := c.UnmarshalYAMLConfig()
 	if errs != nil {
-		helga_errors.HandleErrors(errs)
-		panic(errs)
+		return nil, exitCodeError{code: exitConfigInvalid, err: fmt.Errorf("configuration is invalid, %d errors, refer to logs", len(errs))}
 	}
 
 	logger.GetLoggerInstance().Info("configuration loaded successfully")
-	logger.GetLoggerInstance().Info("starting to initiate clusters")
 
-	for _, c := range helgaConfig.Clusters {
-		c.Init()
+	return c, nil
+}
+
+type namespaceTarget struct {
+	cluster   *models.Cluster
+	namespace *models.Namespace
+}
+
+// initiates the helm client of every namespace with init, namespaces that fail are logged and counted
+func initNamespaces(c *config.Config, init func(*models.Cluster, *models.Namespace) error) (targets []namespaceTarget, failed int) {
+	for _, cl := range c.Clusters {
+		for _, ns := range cl.Namespaces {
+			if err := init(cl, ns); err != nil {
+				helga_errors.HandleError(fmt.Errorf("err occured while initaiting client for namespace: %s of cluster: %s, derived from err: %w", ns.String(), cl.String(), err))
+				failed++
+
+				continue
+			}
+
+			targets = append(targets, namespaceTarget{cluster: cl, namespace: ns})
+		}
+	}
 
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			c.SyncNamespacesWithCluster()
-		}()
+	return
+}
+
+// initiates namespaces inside a temporary helm home so commands that only read can add helm repositories
+// and log in to oci registries without touching the helm configuration of the host, cleanup removes it
+func initNamespacesInTempHelmHome(c *config.Config) (targets []namespaceTarget, failed int, cleanup func(), err error) {
+	helmHome, err := os.MkdirTemp("", "helga-helm-")
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("creating temporary helm home was unsuccesful: %w", err)
 	}
-	wg.Wait()
 
-	logger.GetLoggerInstance().Info("finished.")
+	targets, failed = initNamespaces(c, func(cl *models.Cluster, ns *models.Namespace) error {
+		return cl.InitNamespaceInHelmHome(ns, helmHome)
+	})
+
+	return targets, failed, func() { os.RemoveAll(helmHome) }, nil
 }
 
 func finalize() {
+	// the log file could not be opened
+	if logger.GetInstance() == nil {
+		return
+	}
+
 	logger.GetLoggerInstance().Info("closing log file")
 	logger.GetInstance().CloseLogFile()
 }
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/fennet82/helga/internal/logger"
+	helga_errors "github.com/fennet82/helga/pkg/errors"
+	"github.com/fennet82/helga/pkg/models"
+	"github.com/spf13/cobra"
+)
+
+// plan of one namespace, error is set instead of entries when the namespace could not be planned
+type namespacePlan struct {
+	Cluster   string             `json:"cluster"`
+	Namespace string             `json:"namespace"`
+	Entries   []models.PlanEntry `json:"entries"`
+	Error     string             `json:"error,omitempty"`
+}
+
+func printPlanText(plans []namespacePlan) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CLUSTER\tNAMESPACE\tRELEASE\tCHART\tCURRENT\tCANDIDATE\tSOURCE\tREASON\tACTION")
+
+	for _, p := range plans {
+		if p.Error != "" {
+			fmt.Fprintf(w, "%s\t%s\t-\t-\t-\t-\t-\t%s\t-\n", p.Cluster, p.Namespace, p.Error)
+			continue
+		}
+
+		for _, e := range p.Entries {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				p.Cluster, p.Namespace, e.Release, e.Chart, orDash(e.CurrentVersion), orDash(e.CandidateVersion), orDash(e.Source), e.Reason, e.Action,
+			)
+		}
+	}
+
+	w.Flush()
+
+	for _, p := range plans {
+		for _, e := range p.Entries {
+			if e.Diff == "" {
+				continue
+			}
+
+			fmt.Printf("\n# cluster: %s, namespace: %s, release: %s\n%s", p.Cluster, p.Namespace, e.Release, e.Diff)
+		}
+	}
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
+
+func newPlanCmd() *cobra.Command {
+	var (
+		output    string
+		withDiffs bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Show the changes the next sync pass would make without applying them",
+		Long: "Runs the sync decisions of every configured namespace without installing, upgrading or pruning anything " +
+			"and prints the current and candidate version, the selection reason and the planned action of every release.",
+		Args: cobra.NoArgs,
+		PreRunE: func(_ *cobra.Command, _ []string) error {
+			if output != "text" && output != "json" {
+				return fmt.Errorf("output: %s, needs to be one of: text, json", output)
+			}
+
+			logger.SetConsoleOutput(os.Stderr)
+
+			return nil
+		},
+		RunE: func(_ *cobra.Command, _ []string) error {
+			ctx, stop := signalContext()
+			defer stop()
+
+			c, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			targets, failed, cleanup, err := initNamespacesInTempHelmHome(c)
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			plans := make([]namespacePlan, 0, len(targets))
+
+			for _, t := range targets {
+				p := namespacePlan{Cluster: t.cluster.String(), Namespace: t.namespace.String()}
+
+				entries, err := t.namespace.Plan(ctx, withDiffs)
+				if err != nil {
+					helga_errors.HandleError(err)
+					failed++
+
+					p.Error = err.Error()
+				}
+
+				p.Entries = entries
+				plans = append(plans, p)
+			}
+
+			if output == "json" {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+
+				if err := enc.Encode(plans); err != nil {
+					return err
+				}
+			} else {
+				printPlanText(plans)
+			}
+
+			if failed > 0 {
+				return exitCodeError{code: exitSyncFailed, err: fmt.Errorf("%d namespaces could not be planned, refer to logs", failed)}
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "text", "output format, text or json")
+	cmd.Flags().BoolVar(&withDiffs, "diff", false, "render every planned install and upgrade and show its manifest diff against the deployed release")
+
+	return cmd
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"time"
+
+	"github.com/fennet82/helga/internal/logger"
+	"github.com/fennet82/helga/internal/vars"
+	"github.com/fennet82/helga/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+func newRunCmd() *cobra.Command {
+	var (
+		listenAddr             string
+		livenessSyncIntervals  uint
+		livenessMinStallPeriod time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "run",
+		Short: "Sync every configured namespace continuously and reload the config when it changes",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			ctx, stop := signalContext()
+			defer stop()
+
+			c, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			// namespaces are started by the reloader which keeps them in sync with the config files
+			reloader := config.NewReloader(c)
+
+			serveHTTP(ctx, listenAddr, newHTTPHandler(reloader, livenessSyncIntervals, livenessMinStallPeriod))
+
+			logger.GetLoggerInstance().Info("starting to initiate clusters")
+
+			reloader.Run(ctx)
+
+			logger.GetLoggerInstance().Info("finished.")
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&listenAddr, "listen-address", vars.HTTP_LISTEN_ADDRESS_DEFAULT, "address of the /metrics, /healthz and /readyz endpoints, empty to disable them")
+	cmd.Flags().UintVar(&livenessSyncIntervals, "liveness-sync-intervals", vars.LIVENESS_SYNC_INTERVALS_DEFAULT, "sync intervals a namespace may go without completing a sync cycle before /healthz fails")
+	cmd.Flags().DurationVar(&livenessMinStallPeriod, "liveness-min-stall-period", vars.LIVENESS_MIN_STALL_DEFAULT*time.Second, "shortest time a namespace may go without completing a sync cycle before /healthz fails, 0 to only use the sync intervals")
+
+	return cmd
+}
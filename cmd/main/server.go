@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/fennet82/helga/internal/logger"
+	"github.com/fennet82/helga/internal/metrics"
+	"github.com/fennet82/helga/pkg/config"
+	helga_errors "github.com/fennet82/helga/pkg/errors"
+)
+
+// answers 200 when the check passes and 503 with every problem found otherwise
+func healthHandler(check func() (bool, []string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		ok, problems := check()
+		if ok {
+			fmt.Fprintln(w, "ok")
+			return
+		}
+
+		w.WriteHeader(http.StatusServiceUnavailable)
+
+		for _, p := range problems {
+			fmt.Fprintln(w, p)
+		}
+	}
+}
+
+func newHTTPHandler(reloader *config.Reloader, livenessSyncIntervals uint, livenessMinStallPeriod time.Duration) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	mux.Handle("/readyz", healthHandler(reloader.Ready))
+	mux.Handle("/healthz", healthHandler(func() (bool, []string) {
+		return reloader.Alive(livenessSyncIntervals, livenessMinStallPeriod)
+	}))
+
+	return mux
+}
+
+// serves the http endpoints until ctx is cancelled, an empty address disables the server
+func serveHTTP(ctx context.Context, addr string, handler http.Handler) {
+	if addr == "" {
+		return
+	}
+
+	server := &http.Server{Addr: addr, Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		server.Shutdown(shutdownCtx)
+	}()
+
+	go func() {
+		logger.GetLoggerInstance().Info(fmt.Sprintf("serving http endpoints on: %s", addr))
+
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			helga_errors.HandleError(fmt.Errorf("http server on: %s stopped, err: %w", addr, err))
+		}
+	}()
+}
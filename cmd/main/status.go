@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fennet82/helga/internal/logger"
+	helga_errors "github.com/fennet82/helga/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func newStatusCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "List the releases deployed in every configured namespace and whether helga manages them",
+		Args:  cobra.NoArgs,
+		PreRun: func(_ *cobra.Command, _ []string) {
+			logger.SetConsoleOutput(os.Stderr)
+		},
+		RunE: func(_ *cobra.Command, _ []string) error {
+			c, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			targets, failed, cleanup, err := initNamespacesInTempHelmHome(c)
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "CLUSTER\tNAMESPACE\tRELEASE\tCHART\tVERSION\tSTATUS\tLAST DEPLOYED\tMANAGED")
+
+			for _, t := range targets {
+				statuses, err := t.namespace.ReleaseStatuses()
+				if err != nil {
+					helga_errors.HandleError(err)
+					failed++
+
+					continue
+				}
+
+				for _, s := range statuses {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%t\n",
+						t.cluster.String(), t.namespace.String(), s.Release, s.Chart, s.Version, s.Status, s.LastDeployed.Format(time.RFC3339), s.Managed,
+					)
+				}
+			}
+
+			w.Flush()
+
+			if failed > 0 {
+				return exitCodeError{code: exitSyncFailed, err: fmt.Errorf("status of %d namespaces could not be read, refer to logs", failed)}
+			}
+
+			return nil
+		},
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/fennet82/helga/internal/logger"
+	helga_errors "github.com/fennet82/helga/pkg/errors"
+	"github.com/fennet82/helga/pkg/models"
+	"github.com/spf13/cobra"
+)
+
+func newSyncOnceCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "sync-once",
+		Short: "Run one sync pass over every configured namespace and exit",
+		Long: "Runs one sync pass over every configured namespace and exits with code 0 when every namespace synced, " +
+			"2 when the config is invalid and 3 when any namespace failed to sync.",
+		Args: cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			ctx, stop := signalContext()
+			defer stop()
+
+			c, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			targets, failed := initNamespaces(c, (*models.Cluster).InitNamespace)
+
+			var (
+				wg sync.WaitGroup
+				mu sync.Mutex
+			)
+
+			for _, t := range targets {
+				wg.Add(1)
+
+				go func() {
+					defer wg.Done()
+
+					if err := t.namespace.SyncOnce(ctx); err != nil {
+						helga_errors.HandleError(fmt.Errorf("sync of namespace: %s of cluster: %s failed, err: %w", t.namespace.String(), t.cluster.String(), err))
+
+						mu.Lock()
+						failed++
+						mu.Unlock()
+					}
+				}()
+			}
+
+			wg.Wait()
+
+			if failed > 0 {
+				return exitCodeError{code: exitSyncFailed, err: fmt.Errorf("%d namespaces failed to sync, refer to logs", failed)}
+			}
+
+			logger.GetLoggerInstance().Info(fmt.Sprintf("synced %d namespaces", len(targets)))
+
+			return nil
+		},
+	}
+}
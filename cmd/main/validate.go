@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fennet82/helga/internal/logger"
+	"github.com/spf13/cobra"
+)
+
+func newValidateCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate",
+		Short: "Validate the config without connecting to any cluster",
+		Args:  cobra.NoArgs,
+		PreRun: func(_ *cobra.Command, _ []string) {
+			logger.SetConsoleOutput(os.Stderr)
+		},
+		RunE: func(_ *cobra.Command, _ []string) error {
+			c, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			// unlike the daemon, validate fails on clusters and namespaces that would be skipped
+			if dropped := c.DroppedDefinitions(); len(dropped) > 0 {
+				return exitCodeError{code: exitConfigInvalid, err: fmt.Errorf("definitions did not pass validation: %s", strings.Join(dropped, ", "))}
+			}
+
+			namespaces := 0
+			for _, cl := range c.Clusters {
+				namespaces += len(cl.Namespaces)
+			}
+
+			fmt.Printf("configuration is valid: %d clusters, %d namespaces\n", len(c.Clusters), namespaces)
+
+			return nil
+		},
+	}
+}
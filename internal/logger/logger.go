@@ -2,6 +2,7 @@ package logger
 
 import (
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"sync"
@@ -19,8 +20,15 @@ type logger_wrapper struct {
 var (
 	logger_w *logger_wrapper
 	once     sync.Once
+	// commands printing reports move the console logs to stderr
+	console_output io.Writer = os.Stdout
 )
 
+// needs to be called before the logger is first used
+func SetConsoleOutput(w io.Writer) {
+	console_output = w
+}
+
 func GetInstance() *logger_wrapper {
 	once.Do(func() {
 		logger_w, _ = createLogger(vars.LOGS_FILE_PATH)
@@ -43,7 +51,7 @@ func createLogger(fname string) (*logger_wrapper, error) {
 		logger: *slog.New(
 			slogmulti.Fanout(
 				slog.NewJSONHandler(log_f, &slog.HandlerOptions{}),
-				slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{}),
+				slog.NewTextHandler(console_output, &slog.HandlerOptions{}),
 			),
 		),
 		log_file: *log_f,
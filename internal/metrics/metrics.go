@@ -0,0 +1,168 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "helga"
+
+// results of sync cycles and deployments
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+var (
+	registry = prometheus.NewRegistry()
+
+	aqlRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "aql_requests_total",
+		Help:      "AQL requests sent to artifactory by repo, path and response status.",
+	}, []string{"repo", "path", "status"})
+
+	aqlRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "aql_request_duration_seconds",
+		Help:      "Duration of AQL requests sent to artifactory by repo and path.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"repo", "path"})
+
+	syncCycles = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sync_cycles_total",
+		Help:      "Sync cycles of a namespace by result.",
+	}, []string{"cluster", "namespace", "result"})
+
+	syncCycleDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "sync_cycle_duration_seconds",
+		Help:      "Duration of the sync cycles of a namespace.",
+		Buckets:   []float64{1, 5, 10, 30, 60, 120, 300, 600},
+	}, []string{"cluster", "namespace"})
+
+	lastSuccessfulSync = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_successful_sync_timestamp_seconds",
+		Help:      "Unix time of the last successful sync cycle of a namespace, time() minus it is the time since the last successful sync.",
+	}, []string{"cluster", "namespace"})
+
+	deployments = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "deployments_total",
+		Help:      "Installs and upgrades of a chart by action and result.",
+	}, []string{"cluster", "namespace", "chart", "action", "result"})
+
+	deployedVersion = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "chart_deployed_version_info",
+		Help:      "Version of the chart currently deployed in a namespace, always 1.",
+	}, []string{"cluster", "namespace", "chart", "version"})
+
+	latestVersion = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "chart_latest_version_info",
+		Help:      "Latest version of the chart available in the chart sources of a namespace, always 1.",
+	}, []string{"cluster", "namespace", "chart", "version"})
+
+	upToDate = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "chart_up_to_date",
+		Help:      "1 if the deployed version of the chart is the latest available version, 0 otherwise.",
+	}, []string{"cluster", "namespace", "chart"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		aqlRequests, aqlRequestDuration,
+		syncCycles, syncCycleDuration, lastSuccessfulSync,
+		deployments,
+		deployedVersion, latestVersion, upToDate,
+	)
+}
+
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})
+}
+
+// status is the http status code of the response or "error" when no response was received
+func ObserveAQLRequest(repo, path string, statusCode int, started time.Time) {
+	status := "error"
+	if statusCode != 0 {
+		status = strconv.Itoa(statusCode)
+	}
+
+	aqlRequests.WithLabelValues(repo, path, status).Inc()
+	aqlRequestDuration.WithLabelValues(repo, path).Observe(time.Since(started).Seconds())
+}
+
+func ObserveSyncCycle(cluster, ns string, err error, started time.Time) {
+	syncCycleDuration.WithLabelValues(cluster, ns).Observe(time.Since(started).Seconds())
+
+	if err != nil {
+		syncCycles.WithLabelValues(cluster, ns, ResultFailure).Inc()
+		return
+	}
+
+	syncCycles.WithLabelValues(cluster, ns, ResultSuccess).Inc()
+	lastSuccessfulSync.WithLabelValues(cluster, ns).SetToCurrentTime()
+}
+
+func ObserveDeployment(cluster, ns, chart, action string, err error) {
+	result := ResultSuccess
+	if err != nil {
+		result = ResultFailure
+	}
+
+	deployments.WithLabelValues(cluster, ns, chart, action, result).Inc()
+}
+
+// an empty latest version means the chart was not found in the sources
+func SetChartVersions(cluster, ns, chart, deployed, latest string) {
+	labels := prometheus.Labels{"cluster": cluster, "namespace": ns, "chart": chart}
+
+	// the version label changes with every upgrade so the series of the old version are removed
+	deployedVersion.DeletePartialMatch(labels)
+	latestVersion.DeletePartialMatch(labels)
+
+	deployedVersion.WithLabelValues(cluster, ns, chart, deployed).Set(1)
+
+	if latest == "" {
+		upToDate.Delete(labels)
+		return
+	}
+
+	latestVersion.WithLabelValues(cluster, ns, chart, latest).Set(1)
+
+	if deployed == latest {
+		upToDate.With(labels).Set(1)
+	} else {
+		upToDate.With(labels).Set(0)
+	}
+}
+
+// drops the version series of a chart whose release was pruned
+func ForgetChart(cluster, ns, chart string) {
+	labels := prometheus.Labels{"cluster": cluster, "namespace": ns, "chart": chart}
+
+	for _, vec := range []*prometheus.GaugeVec{deployedVersion, latestVersion, upToDate} {
+		vec.DeletePartialMatch(labels)
+	}
+}
+
+// drops the series of a namespace that is no longer synced
+func ForgetNamespace(cluster, ns string) {
+	labels := prometheus.Labels{"cluster": cluster, "namespace": ns}
+
+	for _, vec := range []*prometheus.GaugeVec{lastSuccessfulSync, deployedVersion, latestVersion, upToDate} {
+		vec.DeletePartialMatch(labels)
+	}
+}
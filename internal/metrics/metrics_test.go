@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSetChartVersions(t *testing.T) {
+	SetChartVersions("prod", "web", "nginx", "1.0.0", "1.1.0")
+
+	if got := testutil.ToFloat64(upToDate.WithLabelValues("prod", "web", "nginx")); got != 0 {
+		t.Errorf("expected nginx not to be up to date before the upgrade, got: %v", got)
+	}
+
+	// the versions after the upgrade replace the series of the old version
+	SetChartVersions("prod", "web", "nginx", "1.1.0", "1.1.0")
+
+	if got := testutil.ToFloat64(upToDate.WithLabelValues("prod", "web", "nginx")); got != 1 {
+		t.Errorf("expected nginx to be up to date after the upgrade, got: %v", got)
+	}
+
+	if got := testutil.CollectAndCount(deployedVersion); got != 1 {
+		t.Errorf("expected a single deployed version series, got: %d", got)
+	}
+
+	ForgetNamespace("prod", "web")
+}
+
+func TestForgetChart(t *testing.T) {
+	SetChartVersions("prod", "web", "nginx", "1.0.0", "1.1.0")
+	SetChartVersions("prod", "web", "redis", "7.0.0", "7.0.0")
+
+	ForgetChart("prod", "web", "nginx")
+
+	for name, count := range map[string]int{
+		"deployed": testutil.CollectAndCount(deployedVersion),
+		"latest":   testutil.CollectAndCount(latestVersion),
+		"upToDate": testutil.CollectAndCount(upToDate),
+	} {
+		if count != 1 {
+			t.Errorf("expected only the %s series of redis to be kept, got: %d series", name, count)
+		}
+	}
+
+	ForgetNamespace("prod", "web")
+}
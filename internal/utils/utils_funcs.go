@@ -2,7 +2,9 @@ package utils
 
 import (
 	"fmt"
+	"path"
 	"reflect"
+	"strings"
 	"sync"
 
 	helga_errors "github.com/fennet82/helga/pkg/errors"
@@ -99,3 +101,97 @@ func CallWithCache(fn any, params ...any) []any {
 	}
 	return res
 }
+
+// matches a slash separated path against a glob pattern where "*" matches
+// within a single segment and "**" matches any amount of segments
+func MatchPathGlob(pattern, p string) bool {
+	return matchSegments(splitPath(pattern), splitPath(p))
+}
+
+func splitPath(p string) []string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return nil
+	}
+
+	return strings.Split(p, "/")
+}
+
+func matchSegments(patternSegs, pathSegs []string) bool {
+	if len(patternSegs) == 0 {
+		return len(pathSegs) == 0
+	}
+
+	if patternSegs[0] == "**" {
+		for i := 0; i <= len(pathSegs); i++ {
+			if matchSegments(patternSegs[1:], pathSegs[i:]) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if len(pathSegs) == 0 {
+		return false
+	}
+
+	if matched, err := path.Match(patternSegs[0], pathSegs[0]); err != nil || !matched {
+		return false
+	}
+
+	return matchSegments(patternSegs[1:], pathSegs[1:])
+}
+
+// converts the map[interface{}]interface{} maps yaml.v2 decodes nested objects into to map[string]any
+func NormalizeYAMLMap(m map[string]any) map[string]any {
+	normalized := make(map[string]any, len(m))
+	for k, v := range m {
+		normalized[k] = normalizeYAMLValue(v)
+	}
+
+	return normalized
+}
+
+func normalizeYAMLValue(v any) any {
+	switch typed := v.(type) {
+	case map[any]any:
+		m := make(map[string]any, len(typed))
+		for k, val := range typed {
+			m[fmt.Sprint(k)] = normalizeYAMLValue(val)
+		}
+
+		return m
+	case map[string]any:
+		return NormalizeYAMLMap(typed)
+	case []any:
+		s := make([]any, len(typed))
+		for i, val := range typed {
+			s[i] = normalizeYAMLValue(val)
+		}
+
+		return s
+	default:
+		return v
+	}
+}
+
+// deep merges src into dst, values of src win and nested maps are merged key by key
+func MergeMaps(dst, src map[string]any) map[string]any {
+	if dst == nil {
+		dst = make(map[string]any, len(src))
+	}
+
+	for k, v := range src {
+		srcMap, srcIsMap := v.(map[string]any)
+		dstMap, dstIsMap := dst[k].(map[string]any)
+
+		if srcIsMap && dstIsMap {
+			dst[k] = MergeMaps(dstMap, srcMap)
+		} else {
+			dst[k] = v
+		}
+	}
+
+	return dst
+}
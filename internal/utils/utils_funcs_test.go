@@ -0,0 +1,36 @@
+package utils
+
+import "testing"
+
+func TestMatchPathGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"charts", "charts", true},
+		{"charts", "charts/stable", false},
+		{"/charts/", "charts", true},
+		{"charts/*/stable", "charts/team-a/stable", true},
+		{"charts/*/stable", "charts/team-a/nested/stable", false},
+		{"charts/*/stable", "charts/stable", false},
+		{"team-a/**", "team-a", true},
+		{"team-a/**", "team-a/web/charts", true},
+		{"team-a/**", "team-b/web", false},
+		{"**/stable", "stable", true},
+		{"**/stable", "a/b/stable", true},
+		{"**/stable", "a/b/stable/old", false},
+		{"a/**/c", "a/c", true},
+		{"a/**/c", "a/b/b/c", true},
+		{"team-?/*", "team-a/web", true},
+		{"team-[", "team-a", false},
+		{"", "", true},
+		{"", "charts", false},
+	}
+
+	for _, tt := range tests {
+		if got := MatchPathGlob(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("MatchPathGlob(%q, %q) = %t, want %t", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
@@ -13,6 +13,25 @@ var (
 const (
 	K8S_API_URL_REGEX               = `^(https?:\/\/)?[a-zA-Z0-9.-]+(:\d+)?$`
 	ARTIFACTORY_VALIDATION_REGEX    = `^(https?:\/\/)?([a-zA-Z0-9-]+\.)*[a-zA-Z0-9-]+\/artifactory$`
+	OCI_REGISTRY_HOST_REGEX         = `^[a-zA-Z0-9.-]+(:\d+)?$`
 	AQL_ARTIFACT_PATH_POSTFIX       = "api/search/aql"
+	AQL_DEFAULT_PAGE_SIZE           = 500
+	ARTIFACTORY_REQUEST_TIMEOUT     = 60
+	HELM_REPOSITORY_REQUEST_TIMEOUT = 60
+	K8S_SECRET_REQUEST_TIMEOUT      = 30
+	CHART_METADATA_CACHE_SIZE       = 1024
 	SYNC_INTERVAL_DEFAULT_RETENTION = 4
+	CONFIG_RELOAD_POLL_INTERVAL     = 10
+	SHUTDOWN_GRACE_PERIOD           = 60
+	HTTP_LISTEN_ADDRESS_DEFAULT     = ":9090"
+	LIVENESS_SYNC_INTERVALS_DEFAULT = 5
+	LIVENESS_MIN_STALL_DEFAULT      = 300
+	PRUNE_DEFAULT_MAX_DELETIONS     = 3
+	PRUNE_DEFAULT_PROTECTION_LABEL  = "helga.io/protected"
+	OWNERSHIP_LABEL_KEY             = "helga.io/managed-by"
+	OWNERSHIP_LABEL_VALUE           = "helga"
+	VALUES_CHECKSUM_LABEL_KEY       = "helga.io/values-checksum"
+	SECRET_REF_ENV_PREFIX           = "env:"
+	SECRET_REF_FILE_PREFIX          = "file:"
+	SECRET_REF_K8S_PREFIX           = "k8s:"
 )
@@ -3,7 +3,6 @@ package config
 import (
 	"errors"
 	"fmt"
-	"os"
 	"regexp"
 
 	"github.com/fennet82/helga/internal/logger"
@@ -11,12 +10,13 @@ import (
 	"github.com/fennet82/helga/internal/vars"
 	helga_errors "github.com/fennet82/helga/pkg/errors"
 	"github.com/fennet82/helga/pkg/models"
-	"gopkg.in/yaml.v2"
 )
 
 type Global struct {
 	Cluster  *models.Cluster  `yaml:"cluster"`
 	Artifact *models.Artifact `yaml:"artifact"`
+
+	models.ValuesOverlay `yaml:",inline"`
 }
 
 func (g *Global) Validate() []error {
@@ -28,6 +28,11 @@ func (g *Global) Validate() []error {
 		dReg           = regexp.MustCompile(vars.ARTIFACTORY_VALIDATION_REGEX)
 	)
 
+	// namespaces without an artifact block pull their charts from other sources, so global needs none either
+	if g.Artifact == nil {
+		return nil
+	}
+
 	// artifact validation
 	if !dReg.MatchString(g.Artifact.Domain) {
 		validationErrs = append(validationErrs, helga_errors.ErrValidation{StructName: structName, DerivedFromErr: fmt.Errorf(
@@ -50,6 +55,29 @@ func (g *Global) Validate() []error {
 type Config struct {
 	Global   *Global           `yaml:"global"`
 	Clusters []*models.Cluster `yaml:"clusters"`
+
+	// clusters and namespaces that were defined but dropped by validation
+	dropped []string
+}
+
+// keys of every cluster and namespace defined in the config in the form cluster/namespace
+func (c *Config) definitionKeys() []string {
+	var keys []string
+
+	for _, cl := range c.Clusters {
+		keys = append(keys, cl.Name)
+
+		for _, ns := range cl.Namespaces {
+			keys = append(keys, cl.Name+"/"+ns.Name)
+		}
+	}
+
+	return keys
+}
+
+// clusters and namespaces that did not pass validation and won't be synced
+func (c *Config) DroppedDefinitions() []string {
+	return c.dropped
 }
 
 func (c *Config) validate() []error {
@@ -84,6 +112,13 @@ func (c *Config) syncWithGlobal() (errs []error) {
 		}
 
 		for _, ns := range cl.Namespaces {
+			ns.InheritValues(&c.Global.ValuesOverlay, &cl.ValuesOverlay)
+
+			// namespaces without an artifact block pull their charts from other sources only
+			if ns.Artifact == nil {
+				continue
+			}
+
 			err := ns.Artifact.Sync(c.Global.Artifact)
 			if err != nil {
 				errs = append(errs, helga_errors.ErrSync{DerivedFromErr: err})
@@ -97,17 +132,31 @@ func (c *Config) syncWithGlobal() (errs []error) {
 func (c *Config) UnmarshalYAMLConfig() []error {
 	var configErrs []error
 
-	// validate correct unmarshelling
-	f, err := os.ReadFile(vars.HELGA_CONF_FILE_PATH)
-	if err != nil {
-		configErrs = append(configErrs, helga_errors.ErrConfigLoadingError{DerivedFromErr: err})
+	// HELGA_CONF_FILE_PATH may point at a single file, a directory or several files
+	loaded, loadErrs := loadConfigFiles(vars.HELGA_CONF_FILE_PATH)
+	if len(loadErrs) > 0 {
+		for _, err := range loadErrs {
+			configErrs = append(configErrs, helga_errors.ErrConfigLoadingError{DerivedFromErr: err})
+		}
+
+		helga_errors.HandleErrors(configErrs)
+
+		return configErrs
+	}
+
+	*c = *loaded
+
+	// every cluster and namespace inherits from global so nothing can be synced without it
+	if c.Global == nil {
+		configErrs = append(configErrs, helga_errors.ErrValidation{StructName: "Config", DerivedFromErr: errors.New("global block is missing from every config file")})
 		helga_errors.HandleErrors(configErrs)
 
 		return configErrs
 	}
 
-	if err := yaml.Unmarshal(f, c); err != nil {
-		configErrs = append(configErrs, helga_errors.ErrConfigLoadingError{DerivedFromErr: err})
+	// credentials are resolved before syncing so namespaces inherit the resolved values
+	if secretErrs := c.resolveSecretRefs(); len(secretErrs) > 0 {
+		configErrs = append(configErrs, secretErrs...)
 		helga_errors.HandleErrors(configErrs)
 
 		return configErrs
@@ -118,5 +167,19 @@ func (c *Config) UnmarshalYAMLConfig() []error {
 		helga_errors.HandleErrors(sync_errs)
 	}
 
-	return c.validate()
+	defined := c.definitionKeys()
+	validationErrs := c.validate()
+
+	valid := make(map[string]struct{})
+	for _, key := range c.definitionKeys() {
+		valid[key] = struct{}{}
+	}
+
+	for _, key := range defined {
+		if _, exists := valid[key]; !exists {
+			c.dropped = append(c.dropped, key)
+		}
+	}
+
+	return validationErrs
 }
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fennet82/helga/internal/vars"
+)
+
+func TestUnmarshalYAMLConfigWithoutGlobal(t *testing.T) {
+	vars.HELGA_CONF_FILE_PATH = writeConfigFiles(t, map[string]string{
+		"clusters.yaml": "clusters:\n  - name: prod\n    server: https://prod:6443\n    namespaces:\n      - name: webapp\n",
+	})
+
+	errs := (&Config{}).UnmarshalYAMLConfig()
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "global block is missing") {
+		t.Fatalf("expected a single missing global error, got: %v", errs)
+	}
+}
+
+func TestUnmarshalYAMLConfigDropsNamespacesWithInvalidConstraints(t *testing.T) {
+	chartsDir := t.TempDir()
+
+	vars.HELGA_CONF_FILE_PATH = writeConfigFiles(t, map[string]string{
+		"helga.yaml": `global:
+  cluster:
+    insecure_skip_tls_verify: true
+  artifact:
+    domain: https://artifacts.example.com/artifactory
+clusters:
+  - name: prod
+    server: https://prod:6443
+    username: helga
+    token: secret
+    namespaces:
+      - name: pinned
+        sync_interval: 300
+        local_directories: [{path: "` + chartsDir + `"}]
+        charts: [{name: webapp, version: "~>one"}]
+      - name: other
+        sync_interval: 300
+        local_directories: [{path: "` + chartsDir + `"}]
+`,
+	})
+
+	c := &Config{}
+	c.UnmarshalYAMLConfig()
+
+	if dropped := c.DroppedDefinitions(); len(dropped) != 1 || dropped[0] != "prod/pinned" {
+		t.Fatalf("expected only namespace: prod/pinned to be dropped, got: %v", dropped)
+	}
+}
+
+func TestGlobalValidateWithoutArtifact(t *testing.T) {
+	if errs := (&Global{}).Validate(); len(errs) != 0 {
+		t.Fatalf("expected a global block without artifact to be valid, got: %v", errs)
+	}
+}
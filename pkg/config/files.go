@@ -0,0 +1,451 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fennet82/helga/internal/logger"
+	helga_errors "github.com/fennet82/helga/pkg/errors"
+	"github.com/fennet82/helga/pkg/models"
+	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// the config path may be a file, a directory of yaml files or a comma separated list of files and globs
+func configFilePaths(spec string) ([]string, error) {
+	var paths []string
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		// files of a directory are merged in lexical order like conf.d directories usually are
+		if info, err := os.Stat(entry); err == nil && info.IsDir() {
+			var matches []string
+			for _, pattern := range []string{"*.yaml", "*.yml"} {
+				m, _ := filepath.Glob(filepath.Join(entry, pattern))
+				matches = append(matches, m...)
+			}
+
+			sort.Strings(matches)
+			paths = append(paths, matches...)
+
+			continue
+		}
+
+		if strings.ContainsAny(entry, "*?[") {
+			matches, err := filepath.Glob(entry)
+			if err != nil {
+				return nil, fmt.Errorf("config path pattern: %s is malformed, err: %w", entry, err)
+			}
+
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("config path pattern: %s did not match any file", entry)
+			}
+
+			sort.Strings(matches)
+			paths = append(paths, matches...)
+
+			continue
+		}
+
+		paths = append(paths, entry)
+	}
+
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no config files found in: %s", spec)
+	}
+
+	return paths, nil
+}
+
+// locations of the definitions in a config file, used to point at conflicting definitions
+type configLocations struct {
+	file         string
+	globalLine   int
+	global       map[string]int // "cluster", "artifact" and "values" blocks of global
+	clustersLine int
+	clusters     map[string]int
+	namespaces   map[string]int // keyed by cluster/namespace
+}
+
+// the first line found is used so a definition that could not be located points at its enclosing block,
+// the line is left out when none was found
+func (l *configLocations) at(lines ...int) string {
+	for _, line := range lines {
+		if line > 0 {
+			return fmt.Sprintf("%s:%d", l.file, line)
+		}
+	}
+
+	return l.file
+}
+
+func (l *configLocations) globalAt(key string) string {
+	return l.at(l.global[key], l.globalLine)
+}
+
+func (l *configLocations) globalValues() string {
+	return l.at(l.global["values"], l.global["values_files"], l.globalLine)
+}
+
+func (l *configLocations) clusterAt(cluster string) string {
+	return l.at(l.clusters[cluster], l.clustersLine)
+}
+
+func (l *configLocations) namespaceAt(cluster, namespace string) string {
+	return l.at(l.namespaces[cluster+"/"+namespace], l.clusters[cluster], l.clustersLine)
+}
+
+// keys merged in from an anchor with << are found as well
+func mappingValue(node *yamlv3.Node, key string) (*yamlv3.Node, *yamlv3.Node) {
+	if node != nil && node.Kind == yamlv3.AliasNode {
+		node = node.Alias
+	}
+
+	if node == nil || node.Kind != yamlv3.MappingNode {
+		return nil, nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i], node.Content[i+1]
+		}
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != "<<" {
+			continue
+		}
+
+		merged := []*yamlv3.Node{node.Content[i+1]}
+		if merged[0].Kind == yamlv3.SequenceNode {
+			merged = merged[0].Content
+		}
+
+		for _, m := range merged {
+			if k, v := mappingValue(m, key); k != nil {
+				return k, v
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+func locateDefinitions(file string, content []byte) *configLocations {
+	locations := &configLocations{
+		file:       file,
+		global:     make(map[string]int),
+		clusters:   make(map[string]int),
+		namespaces: make(map[string]int),
+	}
+
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(content, &doc); err != nil || len(doc.Content) == 0 {
+		return locations
+	}
+
+	root := doc.Content[0]
+
+	if k, global := mappingValue(root, "global"); global != nil {
+		locations.globalLine = k.Line
+
+		for _, key := range []string{"cluster", "artifact", "values", "values_files"} {
+			if k, _ := mappingValue(global, key); k != nil {
+				locations.global[key] = k.Line
+			}
+		}
+	}
+
+	k, clusters := mappingValue(root, "clusters")
+	if clusters == nil {
+		return locations
+	}
+
+	locations.clustersLine = k.Line
+
+	for _, cl := range clusters.Content {
+		_, name := mappingValue(cl, "name")
+		if name == nil {
+			continue
+		}
+
+		locations.clusters[name.Value] = cl.Line
+
+		_, namespaces := mappingValue(cl, "namespaces")
+		if namespaces == nil {
+			continue
+		}
+
+		for _, ns := range namespaces.Content {
+			if _, nsName := mappingValue(ns, "name"); nsName != nil {
+				locations.namespaces[name.Value+"/"+nsName.Value] = ns.Line
+			}
+		}
+	}
+
+	return locations
+}
+
+func readConfigFile(file string) (*Config, *configLocations, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	content, err = interpolateConfig(content)
+	if err != nil {
+		return nil, nil, fmt.Errorf("config file: %s, err: %w", file, err)
+	}
+
+	fileConf := &Config{}
+	if err := yaml.Unmarshal(content, fileConf); err != nil {
+		return nil, nil, fmt.Errorf("config file: %s, err: %w", file, err)
+	}
+
+	dir, err := filepath.Abs(filepath.Dir(file))
+	if err != nil {
+		return nil, nil, fmt.Errorf("config file: %s, err: %w", file, err)
+	}
+
+	resolveValuesFiles(fileConf, dir)
+
+	return fileConf, locateDefinitions(file, content), nil
+}
+
+// values files of every layer declared in a config file are relative to its directory
+func resolveValuesFiles(c *Config, dir string) {
+	if c.Global != nil {
+		c.Global.ResolveValuesFiles(dir)
+
+		if c.Global.Cluster != nil {
+			c.Global.Cluster.ResolveValuesFiles(dir)
+		}
+	}
+
+	for _, cl := range c.Clusters {
+		if cl == nil {
+			continue
+		}
+
+		cl.ResolveValuesFiles(dir)
+
+		for _, ns := range cl.Namespaces {
+			ns.ResolveValuesFiles(dir)
+
+			for _, cc := range ns.Charts {
+				cc.ResolveValuesFiles(dir)
+			}
+		}
+	}
+}
+
+// fields that may only be set by one of the files defining the same object
+func conflictingFields(a, b map[string]string) []string {
+	var conflicts []string
+
+	for field, value := range a {
+		if value != "" && b[field] != "" && value != b[field] {
+			conflicts = append(conflicts, field)
+		}
+	}
+
+	sort.Strings(conflicts)
+
+	return conflicts
+}
+
+func clusterFields(c *models.Cluster) map[string]string {
+	return map[string]string{
+		"server":            c.Server,
+		"username":          c.Username,
+		"password":          c.Password,
+		"token":             c.Token,
+		"ca_cert_file_path": c.CACertFilePath,
+	}
+}
+
+func artifactFields(a *models.Artifact) map[string]string {
+	return map[string]string{
+		"domain":   a.Domain,
+		"username": a.Username,
+		"password": a.Password,
+	}
+}
+
+func hasValues(v *models.ValuesOverlay) bool {
+	return len(v.Values) > 0 || len(v.ValuesFiles) > 0
+}
+
+// merges the config files in order, objects defined in several files are merged with their Sync
+// semantics and every field set to different values in two files is reported as a conflict
+type configMerger struct {
+	merged    *Config
+	global    map[string]string // definition location of the global blocks
+	clusters  map[string]*models.Cluster
+	locations map[string]string // definition location of every cluster and namespace
+}
+
+func newConfigMerger() *configMerger {
+	return &configMerger{
+		merged:    &Config{},
+		global:    make(map[string]string),
+		clusters:  make(map[string]*models.Cluster),
+		locations: make(map[string]string),
+	}
+}
+
+func (m *configMerger) conflict(what, first, second string) error {
+	return helga_errors.ErrConfigConflict{Definition: what, FirstLocation: first, SecondLocation: second}
+}
+
+func (m *configMerger) mergeGlobal(g *Global, loc *configLocations) (errs []error) {
+	if g == nil {
+		return nil
+	}
+
+	if m.merged.Global == nil {
+		m.merged.Global = g
+
+		for _, key := range []string{"cluster", "artifact"} {
+			m.global[key] = loc.globalAt(key)
+		}
+
+		m.global["values"] = loc.globalValues()
+
+		return nil
+	}
+
+	dest := m.merged.Global
+
+	if g.Cluster != nil {
+		if dest.Cluster == nil {
+			dest.Cluster = g.Cluster
+			m.global["cluster"] = loc.globalAt("cluster")
+		} else {
+			for _, field := range conflictingFields(clusterFields(dest.Cluster), clusterFields(g.Cluster)) {
+				errs = append(errs, m.conflict("global.cluster."+field, m.global["cluster"], loc.globalAt("cluster")))
+			}
+
+			dest.Cluster.Sync(g.Cluster)
+		}
+	}
+
+	if g.Artifact != nil {
+		if dest.Artifact == nil {
+			dest.Artifact = g.Artifact
+			m.global["artifact"] = loc.globalAt("artifact")
+		} else {
+			for _, field := range conflictingFields(artifactFields(dest.Artifact), artifactFields(g.Artifact)) {
+				errs = append(errs, m.conflict("global.artifact."+field, m.global["artifact"], loc.globalAt("artifact")))
+			}
+
+			dest.Artifact.Sync(g.Artifact)
+		}
+	}
+
+	if hasValues(&g.ValuesOverlay) {
+		if hasValues(&dest.ValuesOverlay) {
+			errs = append(errs, m.conflict("global values", m.global["values"], loc.globalValues()))
+		} else {
+			dest.ValuesOverlay = g.ValuesOverlay
+			m.global["values"] = loc.globalValues()
+		}
+	}
+
+	return
+}
+
+func (m *configMerger) mergeCluster(cl *models.Cluster, loc *configLocations) (errs []error) {
+	clusterLoc := loc.clusterAt(cl.Name)
+
+	dest, exists := m.clusters[cl.Name]
+	if !exists {
+		m.clusters[cl.Name] = cl
+		m.locations[cl.Name] = clusterLoc
+		m.merged.Clusters = append(m.merged.Clusters, cl)
+
+		for _, ns := range cl.Namespaces {
+			m.locations[cl.Name+"/"+ns.Name] = loc.namespaceAt(cl.Name, ns.Name)
+		}
+
+		return nil
+	}
+
+	for _, field := range conflictingFields(clusterFields(dest), clusterFields(cl)) {
+		errs = append(errs, m.conflict(fmt.Sprintf("cluster: %s field: %s", cl.Name, field), m.locations[cl.Name], clusterLoc))
+	}
+
+	if hasValues(&cl.ValuesOverlay) {
+		if hasValues(&dest.ValuesOverlay) {
+			errs = append(errs, m.conflict(fmt.Sprintf("cluster: %s values", cl.Name), m.locations[cl.Name], clusterLoc))
+		} else {
+			dest.ValuesOverlay = cl.ValuesOverlay
+		}
+	}
+
+	dest.Sync(cl)
+
+	// namespaces are never merged, each one has to be defined in a single file
+	for _, ns := range cl.Namespaces {
+		key := cl.Name + "/" + ns.Name
+		nsLoc := loc.namespaceAt(cl.Name, ns.Name)
+
+		if first, defined := m.locations[key]; defined {
+			errs = append(errs, m.conflict(fmt.Sprintf("namespace: %s of cluster: %s", ns.Name, cl.Name), first, nsLoc))
+			continue
+		}
+
+		m.locations[key] = nsLoc
+		dest.Namespaces = append(dest.Namespaces, ns)
+	}
+
+	return
+}
+
+func (m *configMerger) merge(fileConf *Config, loc *configLocations) (errs []error) {
+	errs = append(errs, m.mergeGlobal(fileConf.Global, loc)...)
+
+	for _, cl := range fileConf.Clusters {
+		if cl == nil {
+			continue
+		}
+
+		errs = append(errs, m.mergeCluster(cl, loc)...)
+	}
+
+	return
+}
+
+// reads and merges every config file the spec points at
+func loadConfigFiles(spec string) (*Config, []error) {
+	files, err := configFilePaths(spec)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	var (
+		errs   []error
+		merger = newConfigMerger()
+	)
+
+	for _, file := range files {
+		logger.GetLoggerInstance().Info(fmt.Sprintf("loading config file: %s", file))
+
+		fileConf, loc, err := readConfigFile(file)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		errs = append(errs, merger.merge(fileConf, loc)...)
+	}
+
+	return merger.merged, errs
+}
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfigFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestLoadConfigFilesConflicts(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string // locations of the reported conflicts, in order
+	}{
+		{
+			name: "clusters merged without conflicts",
+			files: map[string]string{
+				"a.yaml": "clusters:\n  - name: prod\n    server: https://prod\n",
+				"b.yaml": "clusters:\n  - name: prod\n    namespaces:\n      - name: webapp\n",
+			},
+		},
+		{
+			name: "conflicting cluster server",
+			files: map[string]string{
+				"a.yaml": "clusters:\n  - name: prod\n    server: https://prod\n",
+				"b.yaml": "# prod\n\nclusters:\n  - name: prod\n    server: https://other\n",
+			},
+			want: []string{"%[1]s/a.yaml:2 and: %[1]s/b.yaml:4"},
+		},
+		{
+			name: "namespace defined twice",
+			files: map[string]string{
+				"a.yaml": "clusters:\n  - name: prod\n    namespaces:\n      - name: webapp\n",
+				"b.yaml": "clusters:\n  - name: prod\n    namespaces:\n      - name: other\n      - name: webapp\n",
+			},
+			want: []string{"%[1]s/a.yaml:4 and: %[1]s/b.yaml:5"},
+		},
+		{
+			name: "conflicting global artifact",
+			files: map[string]string{
+				"a.yaml": "global:\n  artifact:\n    domain: https://a/artifactory\n",
+				"b.yaml": "global:\n  cluster:\n    server: https://prod\n  artifact:\n    domain: https://b/artifactory\n",
+			},
+			want: []string{"%[1]s/a.yaml:2 and: %[1]s/b.yaml:4"},
+		},
+		{
+			name: "namespace from an alias",
+			files: map[string]string{
+				"a.yaml": "clusters:\n  - name: prod\n    namespaces:\n      - name: webapp\n",
+				"b.yaml": "webapp: &webapp\n  name: webapp\nclusters:\n  - name: prod\n    namespaces:\n      - *webapp\n",
+			},
+			want: []string{"%[1]s/a.yaml:4 and: %[1]s/b.yaml:6"},
+		},
+		{
+			name: "cluster name from a merge key",
+			files: map[string]string{
+				"a.yaml": "clusters:\n  - name: prod\n    server: https://prod\n",
+				"b.yaml": "prod: &prod\n  name: prod\nclusters:\n  - <<: *prod\n    server: https://other\n",
+			},
+			want: []string{"%[1]s/a.yaml:2 and: %[1]s/b.yaml:4"},
+		},
+	}
+
+	for _, tt := range tests {
+		dir := writeConfigFiles(t, tt.files)
+
+		_, errs := loadConfigFiles(dir)
+		if len(errs) != len(tt.want) {
+			t.Errorf("%s: got %d errors: %v, want %d", tt.name, len(errs), errs, len(tt.want))
+			continue
+		}
+
+		for i, err := range errs {
+			want := fmt.Sprintf(tt.want[i], dir)
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: got err: %v, want it to point at: %s", tt.name, err, want)
+			}
+
+			if strings.Contains(err.Error(), ":0") {
+				t.Errorf("%s: got err: %v pointing at line 0", tt.name, err)
+			}
+		}
+	}
+}
+
+func TestConfigLocationsAt(t *testing.T) {
+	loc := &configLocations{
+		file:         "conf.yaml",
+		globalLine:   1,
+		global:       map[string]int{"cluster": 2},
+		clustersLine: 10,
+		clusters:     map[string]int{"prod": 11},
+		namespaces:   map[string]int{"prod/webapp": 14},
+	}
+
+	tests := []struct {
+		got, want string
+	}{
+		{loc.globalAt("cluster"), "conf.yaml:2"},
+		{loc.globalAt("artifact"), "conf.yaml:1"},
+		{loc.globalValues(), "conf.yaml:1"},
+		{loc.clusterAt("prod"), "conf.yaml:11"},
+		{loc.clusterAt("staging"), "conf.yaml:10"},
+		{loc.namespaceAt("prod", "webapp"), "conf.yaml:14"},
+		{loc.namespaceAt("prod", "other"), "conf.yaml:11"},
+		{loc.namespaceAt("staging", "other"), "conf.yaml:10"},
+		{(&configLocations{file: "conf.yaml"}).clusterAt("prod"), "conf.yaml"},
+	}
+
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("got location: %s, want: %s", tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoadConfigFilesResolvesValuesFilesAgainstTheirFile(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"a.yaml": "global:\n  values_files: [\"values/global.yaml\"]\n" +
+			"clusters:\n  - name: prod\n    values_files: [\"/etc/helga/prod.yaml\"]\n" +
+			"    namespaces:\n      - name: webapp\n        values_files: [\"./webapp.yaml\"]\n" +
+			"        charts:\n          - name: nginx\n            values_files: [\"../shared/nginx.yaml\"]\n",
+	})
+
+	// the working directory must not matter
+	t.Chdir(t.TempDir())
+
+	loaded, errs := loadConfigFiles(dir)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	ns := loaded.Clusters[0].Namespaces[0]
+
+	tests := []struct {
+		layer string
+		got   []string
+		want  string
+	}{
+		{"global", loaded.Global.ValuesFiles, filepath.Join(dir, "values", "global.yaml")},
+		{"cluster", loaded.Clusters[0].ValuesFiles, "/etc/helga/prod.yaml"},
+		{"namespace", ns.ValuesFiles, filepath.Join(dir, "webapp.yaml")},
+		{"chart", ns.Charts[0].ValuesFiles, filepath.Join(filepath.Dir(dir), "shared", "nginx.yaml")},
+	}
+
+	for _, tt := range tests {
+		if len(tt.got) != 1 || tt.got[0] != tt.want {
+			t.Errorf("%s values files: %v, want: [%s]", tt.layer, tt.got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// matches ${VAR}, ${VAR:-default} and the $${ escape for a literal ${
+var envVarRegex = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// collects every variable that could not be resolved so they are all reported at once
+type interpolator struct {
+	unresolved map[string]struct{}
+}
+
+func (i *interpolator) markUnresolved(name string) {
+	if i.unresolved == nil {
+		i.unresolved = make(map[string]struct{})
+	}
+
+	i.unresolved[name] = struct{}{}
+}
+
+func (i *interpolator) funcs() template.FuncMap {
+	return template.FuncMap{
+		"env": os.Getenv,
+		"requiredEnv": func(name string) string {
+			value, exists := os.LookupEnv(name)
+			if !exists {
+				i.markUnresolved(name)
+			}
+
+			return value
+		},
+		"default": func(def, value string) string {
+			if value == "" {
+				return def
+			}
+
+			return value
+		},
+		"upper":   strings.ToUpper,
+		"lower":   strings.ToLower,
+		"trim":    strings.TrimSpace,
+		"replace": func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+		"quote":   strconv.Quote,
+		"b64enc":  func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+	}
+}
+
+// templates are only rendered when the config uses them, a literal {{ can be written as {{ "{{" }}
+func (i *interpolator) renderTemplate(content string) (string, error) {
+	if !strings.Contains(content, "{{") {
+		return content, nil
+	}
+
+	t, err := template.New("config").Funcs(i.funcs()).Parse(content)
+	if err != nil {
+		return "", fmt.Errorf("couldn't parse config template: %w", err)
+	}
+
+	var rendered bytes.Buffer
+	if err := t.Execute(&rendered, nil); err != nil {
+		return "", fmt.Errorf("couldn't render config template: %w", err)
+	}
+
+	return rendered.String(), nil
+}
+
+// ${VAR:-default} falls back to the default when the variable is unset or empty like in the shell
+func (i *interpolator) expandEnvVars(content string) string {
+	return envVarRegex.ReplaceAllStringFunc(content, func(match string) string {
+		if match == "$${" {
+			return "${"
+		}
+
+		groups := envVarRegex.FindStringSubmatch(match)
+		name, hasDefault, def := groups[1], groups[2] != "", groups[3]
+
+		value, exists := os.LookupEnv(name)
+		if hasDefault && value == "" {
+			return def
+		}
+
+		if !exists {
+			i.markUnresolved(name)
+		}
+
+		return value
+	})
+}
+
+// matches a line ending a mapping value or sequence entry with a block scalar indicator like | or >-
+var blockScalarRegex = regexp.MustCompile(`(^|[:\s])[|>][0-9+-]*\s*$`)
+
+// the index of the # starting a comment of the line, quoted scalars and template actions are skipped
+// since a # in them is part of the value
+func commentStart(line string) int {
+	var quote byte
+
+	actions := 0
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case strings.HasPrefix(line[i:], "{{"):
+			actions++
+			i++
+		case strings.HasPrefix(line[i:], "}}") && actions > 0:
+			actions--
+			i++
+		case actions > 0:
+		case (c == '"' || c == '\'') && (i == 0 || strings.ContainsRune(" \t[{,", rune(line[i-1]))):
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return i
+		}
+	}
+
+	return -1
+}
+
+func indentation(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// removes the comments so variables and templates in commented out lines are not interpolated,
+// lines are kept so the line numbers of definitions do not change
+func stripComments(content string) string {
+	lines := strings.Split(content, "\n")
+	blockIndent := -1
+
+	for n, line := range lines {
+		// lines of a block scalar are its content, a # in them is not a comment
+		if blockIndent >= 0 {
+			if strings.TrimSpace(line) == "" || indentation(line) > blockIndent {
+				continue
+			}
+
+			blockIndent = -1
+		}
+
+		if idx := commentStart(line); idx >= 0 {
+			line = strings.TrimRight(line[:idx], " \t")
+			lines[n] = line
+		}
+
+		if blockScalarRegex.MatchString(line) {
+			blockIndent = indentation(line)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func interpolateConfig(content []byte) ([]byte, error) {
+	i := &interpolator{}
+
+	rendered, err := i.renderTemplate(stripComments(string(content)))
+	if err != nil {
+		return nil, err
+	}
+
+	expanded := i.expandEnvVars(rendered)
+
+	if len(i.unresolved) > 0 {
+		names := make([]string, 0, len(i.unresolved))
+		for name := range i.unresolved {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		return nil, fmt.Errorf("unresolved variables in config: %s", strings.Join(names, ", "))
+	}
+
+	return []byte(expanded), nil
+}
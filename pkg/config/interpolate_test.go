@@ -0,0 +1,54 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInterpolateConfig(t *testing.T) {
+	t.Setenv("HELGA_TEST_ENV", "Production")
+	t.Setenv("HELGA_TEST_EMPTY", "")
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr string
+	}{
+		{"env var", "name: ${HELGA_TEST_ENV}", "name: Production", ""},
+		{"default of unset var", "interval: ${HELGA_TEST_UNSET:-300}", "interval: 300", ""},
+		{"default of empty var", "interval: ${HELGA_TEST_EMPTY:-300}", "interval: 300", ""},
+		{"escaped", "name: $${HELGA_TEST_ENV}", "name: ${HELGA_TEST_ENV}", ""},
+		{"template", `name: "webapp-{{ requiredEnv "HELGA_TEST_ENV" | lower }}"`, `name: "webapp-production"`, ""},
+		{"unresolved vars are all reported", "a: ${HELGA_TEST_B}\nb: '{{ requiredEnv \"HELGA_TEST_A\" }}'", "", "HELGA_TEST_A, HELGA_TEST_B"},
+		{"commented out line", "# name: ${HELGA_TEST_UNSET}\nname: a", "\nname: a", ""},
+		{"trailing comment", "name: a # was ${HELGA_TEST_UNSET}", "name: a", ""},
+		{"commented out template", "name: a\n  # {{ requiredEnv \"HELGA_TEST_UNSET\" }}", "name: a\n", ""},
+		{"# in quoted value", `password: "p#${HELGA_TEST_ENV}"`, `password: "p#Production"`, ""},
+		{"# without space is no comment", "url: http://host/#${HELGA_TEST_ENV}", "url: http://host/#Production", ""},
+		{"# in template action", `name: {{ replace "x" "#" "axb" }}`, "name: a#b", ""},
+		{"apostrophe in plain value", "description: it's ${HELGA_TEST_ENV} # ${HELGA_TEST_UNSET}", "description: it's Production", ""},
+		{"# in block scalar", "script: |\n  # ${HELGA_TEST_ENV}\nname: a # ${HELGA_TEST_UNSET}", "script: |\n  # Production\nname: a", ""},
+	}
+
+	for _, tt := range tests {
+		got, err := interpolateConfig([]byte(tt.content))
+
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: expected an error containing %q, got: %v", tt.name, tt.wantErr, err)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected err: %v", tt.name, err)
+			continue
+		}
+
+		if string(got) != tt.want {
+			t.Errorf("%s: interpolateConfig() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fennet82/helga/internal/vars"
+)
+
+func TestMain(m *testing.M) {
+	logsDir, err := os.MkdirTemp("", "helga-config-test")
+	if err != nil {
+		panic(err)
+	}
+
+	vars.LOGS_FILE_PATH = filepath.Join(logsDir, "helga.log")
+
+	code := m.Run()
+
+	os.RemoveAll(logsDir)
+	os.Exit(code)
+}
@@ -0,0 +1,315 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fennet82/helga/internal/logger"
+	"github.com/fennet82/helga/internal/metrics"
+	"github.com/fennet82/helga/internal/vars"
+	helga_errors "github.com/fennet82/helga/pkg/errors"
+	"github.com/fennet82/helga/pkg/models"
+	"gopkg.in/yaml.v2"
+)
+
+type runningNamespace struct {
+	namespace   *models.Namespace
+	fingerprint string
+	cancel      context.CancelFunc
+	done        chan struct{}
+}
+
+// runs a sync goroutine for every namespace of the config and applies config changes
+// by starting, stopping and restarting only the namespaces that changed
+type Reloader struct {
+	current  *Config
+	running  map[string]*runningNamespace // keyed by cluster/namespace
+	filesSum string
+
+	// only the Run goroutine changes the state, mu guards it against the health checks reading it
+	mu          sync.Mutex
+	initialized bool
+	initErrs    map[string]error // namespaces whose helm client could not be initiated
+
+	// a namespace could not be initiated, the current config is applied again on every poll until it could
+	retryInit bool
+}
+
+func NewReloader(c *Config) *Reloader {
+	return &Reloader{current: c, running: make(map[string]*runningNamespace), initErrs: make(map[string]error)}
+}
+
+// hash of every config file so changes are detected regardless of modification times
+func configFilesChecksum(spec string) (string, error) {
+	files, err := configFilePaths(spec)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+
+		h.Write([]byte(file))
+		h.Write(content)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// covers everything a namespace sync depends on, the connection settings of its cluster and its inherited values
+func namespaceFingerprint(c *Config, cl *models.Cluster, ns *models.Namespace) string {
+	connection := *cl
+	connection.Namespaces = nil
+
+	var globalValues models.ValuesOverlay
+	if c.Global != nil {
+		globalValues = c.Global.ValuesOverlay
+	}
+
+	data, err := yaml.Marshal(struct {
+		Global    models.ValuesOverlay `yaml:"global"`
+		Cluster   models.Cluster       `yaml:"cluster"`
+		Namespace *models.Namespace    `yaml:"namespace"`
+	}{globalValues, connection, ns})
+	if err != nil {
+		// an unmarshalable namespace is always treated as changed
+		return fmt.Sprintf("%p", ns)
+	}
+
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+func (r *Reloader) startNamespace(ctx context.Context, key string, cl *models.Cluster, ns *models.Namespace, fingerprint string) {
+	nsCtx, cancel := context.WithCancel(ctx)
+	rn := &runningNamespace{namespace: ns, fingerprint: fingerprint, cancel: cancel, done: make(chan struct{})}
+
+	// the first cycle is measured from the start, before the health checks can see the namespace
+	ns.MarkCycleCompleted()
+
+	go func() {
+		defer close(rn.done)
+		ns.SyncHelmPkgsWithCluster(nsCtx)
+	}()
+
+	r.mu.Lock()
+	r.running[key] = rn
+	r.mu.Unlock()
+
+	logger.GetLoggerInstance().Info(fmt.Sprintf("started syncing namespace: %s of cluster: %s", ns.String(), cl.String()))
+}
+
+// waits for the running sync cycle of the namespace to finish
+func (r *Reloader) stopNamespace(key string) {
+	rn, exists := r.running[key]
+	if !exists {
+		return
+	}
+
+	rn.cancel()
+	<-rn.done
+
+	r.mu.Lock()
+	delete(r.running, key)
+	r.mu.Unlock()
+}
+
+// namespaces are already cancelled through the root context, helga exits once they finished
+// or the grace period passed
+func (r *Reloader) stopAll(grace time.Duration) {
+	deadline := time.After(grace)
+
+	for key, rn := range r.running {
+		rn.cancel()
+
+		select {
+		case <-rn.done:
+			r.mu.Lock()
+			delete(r.running, key)
+			r.mu.Unlock()
+		case <-deadline:
+			helga_errors.HandleError(fmt.Errorf("namespaces did not stop within the grace period of %s, exiting", grace))
+			return
+		}
+	}
+
+	logger.GetLoggerInstance().Info("all namespaces stopped")
+}
+
+// starts new namespaces, restarts changed ones and stops the ones that are no longer in the config
+func (r *Reloader) apply(ctx context.Context, c *Config) {
+	desired := make(map[string]string)
+	initErrs := make(map[string]error)
+	retryInit := false
+
+	for _, cl := range c.Clusters {
+		for _, ns := range cl.Namespaces {
+			key := cl.Name + "/" + ns.Name
+			fingerprint := namespaceFingerprint(c, cl, ns)
+			desired[key] = fingerprint
+
+			rn, exists := r.running[key]
+			if exists && rn.fingerprint == fingerprint {
+				continue
+			}
+
+			// the running namespace is only replaced once the new one could be initiated
+			if err := cl.InitNamespace(ns); err != nil {
+				helga_errors.HandleError(fmt.Errorf("err occured while initaiting client for namespace: %s, not applying its changes, derived from err: %w", key, err))
+
+				retryInit = true
+				if !exists {
+					initErrs[key] = err
+				}
+
+				continue
+			}
+
+			if exists {
+				logger.GetLoggerInstance().Info(fmt.Sprintf("namespace: %s changed, restarting it", key))
+				r.stopNamespace(key)
+			}
+
+			r.startNamespace(ctx, key, cl, ns, fingerprint)
+		}
+	}
+
+	for key := range r.running {
+		if _, exists := desired[key]; !exists {
+			logger.GetLoggerInstance().Info(fmt.Sprintf("namespace: %s was removed from the config, stopping it", key))
+			r.stopNamespace(key)
+
+			clusterName, nsName, _ := strings.Cut(key, "/")
+			metrics.ForgetNamespace(clusterName, nsName)
+		}
+	}
+
+	r.retryInit = retryInit
+
+	r.mu.Lock()
+	r.current = c
+	r.initialized = true
+	r.initErrs = initErrs
+	r.mu.Unlock()
+}
+
+// ready once the config is applied and the helm client of every namespace is initiated
+func (r *Reloader) Ready() (bool, []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.initialized {
+		return false, []string{"configuration is not applied yet"}
+	}
+
+	var problems []string
+	for key, err := range r.initErrs {
+		problems = append(problems, fmt.Sprintf("namespace: %s helm client is not initiated: %s", key, err.Error()))
+	}
+
+	sort.Strings(problems)
+
+	return len(problems) == 0, problems
+}
+
+// alive unless a namespace did not complete a sync cycle within syncIntervals times its sync interval,
+// never less than minStallPeriod so slow cycles of namespaces with short intervals are tolerated
+func (r *Reloader) Alive(syncIntervals uint, minStallPeriod time.Duration) (bool, []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var stalled []string
+
+	for key, rn := range r.running {
+		allowed := max(time.Duration(syncIntervals)*time.Duration(rn.namespace.SyncInterval)*time.Second, minStallPeriod)
+
+		if since := time.Since(rn.namespace.LastCycleCompleted()); since > allowed {
+			stalled = append(stalled, fmt.Sprintf("namespace: %s did not complete a sync cycle for %s", key, since.Round(time.Second)))
+		}
+	}
+
+	sort.Strings(stalled)
+
+	return len(stalled) == 0, stalled
+}
+
+func loadConfig() (c *Config, errs []error) {
+	c = &Config{}
+	errs = c.UnmarshalYAMLConfig()
+
+	if dropped := c.DroppedDefinitions(); len(dropped) > 0 {
+		errs = append(errs, fmt.Errorf("definitions did not pass validation: %s", strings.Join(dropped, ", ")))
+	}
+
+	return
+}
+
+// reloads the config when its files changed, a config that does not load or validate
+// completely is rejected and the running config is kept. namespaces that could not be
+// initiated are retried on every poll even when the files did not change
+func (r *Reloader) reload(ctx context.Context) {
+	sum, err := configFilesChecksum(vars.HELGA_CONF_FILE_PATH)
+	if err != nil {
+		helga_errors.HandleError(fmt.Errorf("couldn't read config files for reload, keeping running config, err: %w", err))
+		return
+	}
+
+	if sum == r.filesSum {
+		if r.retryInit {
+			logger.GetLoggerInstance().Info("retrying initiation of namespaces that could not be initiated")
+			r.apply(ctx, r.current)
+		}
+
+		return
+	}
+
+	r.filesSum = sum
+
+	logger.GetLoggerInstance().Info("config files changed, reloading configuration")
+
+	c, errs := loadConfig()
+	if len(errs) > 0 {
+		helga_errors.HandleErrors(errs)
+		helga_errors.HandleError(helga_errors.ErrConfigNotValid{DerivedFromErr: fmt.Errorf("rejected reloaded config with %d errors, keeping running config", len(errs))})
+
+		return
+	}
+
+	r.apply(ctx, c)
+
+	logger.GetLoggerInstance().Info("configuration reloaded successfully")
+}
+
+// runs the namespaces of the current config and watches the config files until ctx is cancelled,
+// then stops every namespace after its running sync cycle
+func (r *Reloader) Run(ctx context.Context) {
+	r.filesSum, _ = configFilesChecksum(vars.HELGA_CONF_FILE_PATH)
+	r.apply(ctx, r.current)
+
+	ticker := time.NewTicker(vars.CONFIG_RELOAD_POLL_INTERVAL * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			r.stopAll(vars.SHUTDOWN_GRACE_PERIOD * time.Second)
+
+			return
+		case <-ticker.C:
+			r.reload(ctx)
+		}
+	}
+}
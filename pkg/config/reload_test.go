@@ -0,0 +1,123 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fennet82/helga/internal/vars"
+	"github.com/fennet82/helga/pkg/models"
+)
+
+func testConfig(server string, namespaces ...string) *Config {
+	cl := &models.Cluster{Name: "prod", Server: server, InsecureSkipTLSVerify: true}
+	for _, name := range namespaces {
+		cl.Namespaces = append(cl.Namespaces, &models.Namespace{Name: name, SyncInterval: 3600})
+	}
+
+	return &Config{Clusters: []*models.Cluster{cl}}
+}
+
+func TestNamespaceFingerprint(t *testing.T) {
+	base := testConfig("https://prod:6443", "webapp", "api")
+	fingerprint := namespaceFingerprint(base, base.Clusters[0], base.Clusters[0].Namespaces[0])
+
+	tests := []struct {
+		name    string
+		change  func(c *Config)
+		changed bool
+	}{
+		{"unchanged", func(c *Config) {}, false},
+		{"other namespace changed", func(c *Config) { c.Clusters[0].Namespaces[1].SyncInterval = 60 }, false},
+		{"namespace changed", func(c *Config) { c.Clusters[0].Namespaces[0].SyncInterval = 60 }, true},
+		{"cluster connection changed", func(c *Config) { c.Clusters[0].Server = "https://other:6443" }, true},
+		{"global values changed", func(c *Config) {
+			c.Global = &Global{ValuesOverlay: models.ValuesOverlay{Values: map[string]any{"replicas": 2}}}
+		}, true},
+	}
+
+	for _, tt := range tests {
+		c := testConfig("https://prod:6443", "webapp", "api")
+		tt.change(c)
+
+		got := namespaceFingerprint(c, c.Clusters[0], c.Clusters[0].Namespaces[0])
+		if (got != fingerprint) != tt.changed {
+			t.Errorf("%s: fingerprint changed: %t, want: %t", tt.name, got != fingerprint, tt.changed)
+		}
+	}
+}
+
+func TestReloadRetriesNamespacesNotInitiated(t *testing.T) {
+	vars.HELGA_CONF_FILE_PATH = filepath.Join(t.TempDir(), "helga.yaml")
+	if err := os.WriteFile(vars.HELGA_CONF_FILE_PATH, []byte("clusters: []\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	r := NewReloader(testConfig("https://127.0.0.1:1", "webapp"))
+	r.filesSum, _ = configFilesChecksum(vars.HELGA_CONF_FILE_PATH)
+	defer func() {
+		cancel()
+		r.stopAll(time.Minute)
+	}()
+
+	// unchanged files are not reloaded while every namespace is initiated
+	r.reload(ctx)
+	if len(r.running) != 0 {
+		t.Fatalf("expected no namespace to be started without a change, got: %d", len(r.running))
+	}
+
+	// a namespace that could not be initiated is started on the next poll
+	r.retryInit = true
+	r.reload(ctx)
+
+	if _, running := r.running["prod/webapp"]; !running {
+		t.Fatalf("expected namespace: prod/webapp to be retried and started")
+	}
+
+	if r.retryInit {
+		t.Errorf("expected no retry once every namespace is initiated")
+	}
+
+	if ready, problems := r.Ready(); !ready {
+		t.Errorf("expected reloader to be ready, got: %v", problems)
+	}
+
+	// started namespaces are alive before their first cycle completed
+	if alive, stalled := r.Alive(1, 0); !alive {
+		t.Errorf("expected started namespace to be alive, got: %v", stalled)
+	}
+}
+
+func TestReloaderAlive(t *testing.T) {
+	completed := &models.Namespace{Name: "completed", SyncInterval: 60}
+	completed.MarkCycleCompleted()
+
+	never := &models.Namespace{Name: "never", SyncInterval: 60}
+
+	tests := []struct {
+		name           string
+		namespace      *models.Namespace
+		syncIntervals  uint
+		minStallPeriod time.Duration
+		alive          bool
+	}{
+		{"completed within the sync intervals", completed, 5, 0, true},
+		{"never completed a cycle", never, 5, 0, false},
+		{"never completed within the minimum stall period", never, 5, 100 * 365 * 24 * time.Hour, true},
+		{"no sync intervals allowed", completed, 0, 0, false},
+		{"minimum stall period", completed, 0, time.Minute, true},
+	}
+
+	for _, tt := range tests {
+		r := NewReloader(&Config{})
+		r.running["prod/"+tt.namespace.Name] = &runningNamespace{namespace: tt.namespace}
+
+		if alive, stalled := r.Alive(tt.syncIntervals, tt.minStallPeriod); alive != tt.alive {
+			t.Errorf("%s: alive: %t, want: %t, stalled: %v", tt.name, alive, tt.alive, stalled)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fennet82/helga/internal/logger"
+	"github.com/fennet82/helga/internal/vars"
+	helga_errors "github.com/fennet82/helga/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// resolves credential fields written as env:VAR, file:/path or k8s:namespace/secret#key,
+// any other value is kept as a plaintext credential
+type secretResolver struct {
+	k8sClient kubernetes.Interface
+	// secrets are fetched once per config load
+	k8sSecrets map[string]map[string][]byte
+}
+
+func isSecretRef(value string) bool {
+	return strings.HasPrefix(value, vars.SECRET_REF_ENV_PREFIX) ||
+		strings.HasPrefix(value, vars.SECRET_REF_FILE_PREFIX) ||
+		strings.HasPrefix(value, vars.SECRET_REF_K8S_PREFIX)
+}
+
+func (r *secretResolver) resolve(ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, vars.SECRET_REF_ENV_PREFIX):
+		name := strings.TrimPrefix(ref, vars.SECRET_REF_ENV_PREFIX)
+
+		value, exists := os.LookupEnv(name)
+		if !exists {
+			return "", fmt.Errorf("environment variable: %s is not set", name)
+		}
+
+		return value, nil
+	case strings.HasPrefix(ref, vars.SECRET_REF_FILE_PREFIX):
+		data, err := os.ReadFile(strings.TrimPrefix(ref, vars.SECRET_REF_FILE_PREFIX))
+		if err != nil {
+			return "", err
+		}
+
+		// mounted secrets usually end with a newline
+		return strings.TrimRight(string(data), "\r\n"), nil
+	default:
+		return r.resolveK8sSecret(strings.TrimPrefix(ref, vars.SECRET_REF_K8S_PREFIX))
+	}
+}
+
+// ref is in the form namespace/secret#key
+func (r *secretResolver) resolveK8sSecret(ref string) (string, error) {
+	secretPath, key, found := strings.Cut(ref, "#")
+	namespace, name, foundNs := strings.Cut(secretPath, "/")
+
+	if !found || !foundNs || namespace == "" || name == "" || key == "" {
+		return "", fmt.Errorf("kubernetes secret reference needs to be in the form namespace/secret#key")
+	}
+
+	data, cached := r.k8sSecrets[secretPath]
+	if !cached {
+		client, err := r.kubernetesClient()
+		if err != nil {
+			return "", err
+		}
+
+		logger.GetLoggerInstance().Info(fmt.Sprintf("fetching secret: %s from namespace: %s", name, namespace))
+
+		// an unreachable api server would otherwise block loading the config forever
+		ctx, cancel := context.WithTimeout(context.Background(), vars.K8S_SECRET_REQUEST_TIMEOUT*time.Second)
+		defer cancel()
+
+		secret, err := client.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+
+		if r.k8sSecrets == nil {
+			r.k8sSecrets = make(map[string]map[string][]byte)
+		}
+
+		data = secret.Data
+		r.k8sSecrets[secretPath] = data
+	}
+
+	value, exists := data[key]
+	if !exists {
+		return "", fmt.Errorf("key: %s does not exist in secret: %s", key, secretPath)
+	}
+
+	return string(value), nil
+}
+
+// uses the kubeconfig from the environment or the in cluster service account helga runs with
+func (r *secretResolver) kubernetesClient() (kubernetes.Interface, error) {
+	if r.k8sClient != nil {
+		return r.k8sClient, nil
+	}
+
+	restConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		clientcmd.NewDefaultClientConfigLoadingRules(), &clientcmd.ConfigOverrides{},
+	).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't load kubernetes config for resolving secrets: %w", err)
+	}
+
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	r.k8sClient = client
+
+	return client, nil
+}
+
+// replaces the reference in place, field is only used for error messages
+func (r *secretResolver) resolveField(field string, value *string) error {
+	if !isSecretRef(*value) {
+		return nil
+	}
+
+	resolved, err := r.resolve(*value)
+	if err != nil {
+		return helga_errors.ErrSecretRef{DerivedFromErr: err, Field: field, Ref: *value}
+	}
+
+	*value = resolved
+
+	return nil
+}
+
+func (c *Config) resolveSecretRefs() (errs []error) {
+	r := &secretResolver{}
+
+	resolve := func(field string, value *string) {
+		if err := r.resolveField(field, value); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if c.Global != nil {
+		if c.Global.Artifact != nil {
+			resolve("global.artifact.password", &c.Global.Artifact.Password)
+		}
+
+		if c.Global.Cluster != nil {
+			resolve("global.cluster.password", &c.Global.Cluster.Password)
+			resolve("global.cluster.token", &c.Global.Cluster.Token)
+		}
+	}
+
+	for _, cl := range c.Clusters {
+		resolve(fmt.Sprintf("cluster: %s password", cl.Name), &cl.Password)
+		resolve(fmt.Sprintf("cluster: %s token", cl.Name), &cl.Token)
+
+		for _, ns := range cl.Namespaces {
+			if ns.Artifact != nil {
+				resolve(fmt.Sprintf("namespace: %s artifact password", ns.Name), &ns.Artifact.Password)
+			}
+
+			for _, o := range ns.OCIRegistries {
+				resolve(fmt.Sprintf("namespace: %s oci registry: %s password", ns.Name, o.Host), &o.Password)
+			}
+
+			for _, h := range ns.HelmRepositories {
+				resolve(fmt.Sprintf("namespace: %s helm repository: %s password", ns.Name, h.Name), &h.Password)
+			}
+		}
+	}
+
+	return
+}
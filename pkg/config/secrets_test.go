@@ -0,0 +1,95 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	helga_errors "github.com/fennet82/helga/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSecretResolverResolveField(t *testing.T) {
+	t.Setenv("HELGA_TEST_PASSWORD", "from-env")
+
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "password")
+	if err := os.WriteFile(secretFile, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatalf("writing secret file: %v", err)
+	}
+
+	client := fake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "helga", Name: "credentials"},
+		Data:       map[string][]byte{"token": []byte("from-k8s")},
+	})
+
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"plaintext", "plain-password", "plain-password", false},
+		{"env", "env:HELGA_TEST_PASSWORD", "from-env", false},
+		{"env not set", "env:HELGA_TEST_MISSING", "", true},
+		{"file trims the trailing newline", "file:" + secretFile, "from-file", false},
+		{"file missing", "file:" + filepath.Join(dir, "missing"), "", true},
+		{"k8s", "k8s:helga/credentials#token", "from-k8s", false},
+		{"k8s missing key", "k8s:helga/credentials#password", "", true},
+		{"k8s missing secret", "k8s:helga/missing#token", "", true},
+		{"k8s without key", "k8s:helga/credentials", "", true},
+		{"k8s without namespace", "k8s:credentials#token", "", true},
+		{"k8s empty name", "k8s:helga/#token", "", true},
+	}
+
+	for _, tt := range tests {
+		r := &secretResolver{k8sClient: client}
+		value := tt.value
+
+		err := r.resolveField("password", &value)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%s: expected an error: %t, got: %v", tt.name, tt.wantErr, err)
+		}
+
+		if tt.wantErr {
+			var refErr helga_errors.ErrSecretRef
+			if !errors.As(err, &refErr) || refErr.Ref != tt.value {
+				t.Errorf("%s: expected an ErrSecretRef for: %s, got: %v", tt.name, tt.value, err)
+			}
+
+			if value != tt.value {
+				t.Errorf("%s: expected the reference to be kept on error, got: %s", tt.name, value)
+			}
+
+			continue
+		}
+
+		if value != tt.want {
+			t.Errorf("%s: resolved to %q, want %q", tt.name, value, tt.want)
+		}
+	}
+}
+
+// every reference to the same secret is served by a single request
+func TestSecretResolverFetchesK8sSecretsOnce(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "helga", Name: "credentials"},
+		Data:       map[string][]byte{"username": []byte("helga"), "token": []byte("from-k8s")},
+	})
+
+	r := &secretResolver{k8sClient: client}
+
+	for _, ref := range []string{"k8s:helga/credentials#username", "k8s:helga/credentials#token"} {
+		value := ref
+		if err := r.resolveField("field", &value); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if gets := len(client.Actions()); gets != 1 {
+		t.Errorf("expected the secret to be fetched once, got: %d requests", gets)
+	}
+}
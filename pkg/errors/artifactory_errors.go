@@ -13,6 +13,18 @@ func (e ErrArtifactoryAPI) Error() string {
 		e.Repo, e.Path, e.DerivedFromErr.Error())
 }
 
+// the listing of a repo path was cut short by max_results, the pkgs fetched are still used
+// but the listing cannot prove that a chart is gone
+type ErrIncompleteListing struct {
+	Repo    string
+	Path    string
+	Fetched uint
+}
+
+func (e ErrIncompleteListing) Error() string {
+	return fmt.Sprintf("listing of repo: %s, path: %s was cut short after %d pkgs by max_results", e.Repo, e.Path, e.Fetched)
+}
+
 type ErrPkgsDoNotMatch struct {
 	ErrMsg string
 }
@@ -27,3 +27,23 @@ func (e ErrConfigNotValid) Error() string {
 func (e ErrSync) Error() string {
 	return fmt.Sprintf("error syncing configuration with global for helga please check the configuration again derived from error: %s", e.DerivedFromErr.Error())
 }
+
+type ErrSecretRef struct {
+	DerivedFromErr error
+	Field          string
+	Ref            string
+}
+
+func (e ErrSecretRef) Error() string {
+	return fmt.Sprintf("error resolving secret reference: %s of field: %s derived from error: %s", e.Ref, e.Field, e.DerivedFromErr.Error())
+}
+
+type ErrConfigConflict struct {
+	Definition     string
+	FirstLocation  string
+	SecondLocation string
+}
+
+func (e ErrConfigConflict) Error() string {
+	return fmt.Sprintf("conflicting definitions of %s at: %s and: %s", e.Definition, e.FirstLocation, e.SecondLocation)
+}
@@ -0,0 +1,14 @@
+package errors
+
+import "fmt"
+
+type ErrHelmRepositoryAPI struct {
+	DerivedFromErr error
+	Repo           string
+	URL            string
+}
+
+func (e ErrHelmRepositoryAPI) Error() string {
+	return fmt.Sprintf("general helm repository API error, repo name:%s, url:%s, error: %s",
+		e.Repo, e.URL, e.DerivedFromErr.Error())
+}
@@ -0,0 +1,14 @@
+package errors
+
+import "fmt"
+
+type ErrOCIRegistryAPI struct {
+	DerivedFromErr error
+	Registry       string
+	Repository     string
+}
+
+func (e ErrOCIRegistryAPI) Error() string {
+	return fmt.Sprintf("general OCI registry API error, registry:%s, repository:%s, error: %s",
+		e.Registry, e.Repository, e.DerivedFromErr.Error())
+}
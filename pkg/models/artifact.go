@@ -2,13 +2,18 @@ package models
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"regexp"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/fennet82/helga/internal/logger"
+	"github.com/fennet82/helga/internal/metrics"
 	"github.com/fennet82/helga/internal/utils"
 	"github.com/fennet82/helga/internal/vars"
 	helga_errors "github.com/fennet82/helga/pkg/errors"
@@ -16,10 +21,16 @@ import (
 )
 
 type Artifact struct {
-	Domain   string  `yaml:"domain"`
-	Username string  `yaml:"username"`
-	Password string  `yaml:"password"`
-	Repos    []*Repo `yaml:"repos"`
+	Domain     string  `yaml:"domain"`
+	Username   string  `yaml:"username"`
+	Password   string  `yaml:"password"`
+	PageSize   uint    `yaml:"page_size,omitempty"`   // amount of pkgs fetched per aql request
+	MaxResults uint    `yaml:"max_results,omitempty"` // max pkgs fetched per repo path, unlimited if 0
+	Repos      []*Repo `yaml:"repos"`
+
+	// shared by every request to the artifactory, created on first use
+	client     *http.Client
+	clientOnce sync.Once
 }
 
 func (a *Artifact) String() string {
@@ -80,6 +91,14 @@ func (dest *Artifact) Sync(src *Artifact) error {
 		dest.Password = src.Password
 	}
 
+	if src.PageSize != 0 && dest.PageSize == 0 {
+		dest.PageSize = src.PageSize
+	}
+
+	if src.MaxResults != 0 && dest.MaxResults == 0 {
+		dest.MaxResults = src.MaxResults
+	}
+
 	syncReposList(&dest.Repos, &src.Repos)
 
 	return nil
@@ -123,98 +142,223 @@ func (a *Artifact) GetArtifactReposAsEntries() []repo.Entry {
 	return helmRepoEntries
 }
 
-func (a *Artifact) GetChartPkgsInArtifact() map[string]HelmChart {
-	aqlQuery := `
-items.find({
-	"repo": {"eq": "%s"},
-	"path": {"eq": "%s"},
-	"name": {"match": "*.tgz"}
-})
-`
-	type ArtifactoryResponse struct {
-		Results []ArtifactHelmPackage `json:"results"`
-	}
+func (a *Artifact) httpClient() *http.Client {
+	a.clientOnce.Do(func() {
+		a.client = &http.Client{Timeout: vars.ARTIFACTORY_REQUEST_TIMEOUT * time.Second}
+	})
+
+	return a.client
+}
+
+// a listing cut short by max_results is returned together with an ErrIncompleteListing for every such path
+func (a *Artifact) GetChartPkgsInArtifact(ctx context.Context, accept ChartFilter) (map[string]HelmChart, error) {
+	var incomplete []error
 
 	artifactoryHelmPackages := make(map[string]HelmChart)
-	client := http.Client{}
+	client := a.httpClient()
 
 	for _, r := range a.Repos {
 		for _, p := range r.Paths {
-			logger.GetLoggerInstance().Info(fmt.Sprintf("sending request to fetch helm pkgs for repo: %s, path: %s", r.String(), p))
+			seenInPath := make(map[string]struct{})
 
-			req, err := http.NewRequest("POST", a.Domain+"/"+vars.AQL_ARTIFACT_PATH_POSTFIX, bytes.NewBufferString(fmt.Sprintf(aqlQuery, r.Name, p)))
-			if err != nil {
-				err := helga_errors.ErrArtifactoryAPI{
-					DerivedFromErr: fmt.Errorf("generating request to the artifactory was unsuccesful"),
-					Repo:           r.String(),
-					Path:           p,
+			complete, err := a.queryRepoPath(ctx, client, r, p, func(resPkg ArtifactHelmPackage) {
+				if !r.matchesPath(p, resPkg.Path) || !r.matchesProperties(resPkg) {
+					return
 				}
-				helga_errors.HandleError(err)
-				panic(err)
-			}
 
-			req.SetBasicAuth(a.Username, a.Password)
-			req.Header.Set("Content-Type", "text/plain")
-
-			resp, err := client.Do(req)
-			if err != nil {
-				err := helga_errors.ErrArtifactoryAPI{
-					DerivedFromErr: fmt.Errorf("request to the artifactory was unsuccesful"),
-					Repo:           r.String(),
-					Path:           p,
+				if !r.DecideByVersion && isArchiveOfCharts(resPkg.FullName, seenInPath) {
+					return
 				}
-				helga_errors.HandleError(err)
-				panic(err)
-			}
 
-			defer resp.Body.Close()
+				a.resolveChartMetadata(ctx, client, &resPkg)
 
-			if resp.StatusCode != http.StatusOK {
-				err := helga_errors.ErrArtifactoryAPI{
-					DerivedFromErr: fmt.Errorf("request to the artifactory was unsuccesful returned status code: %d, needs to be %d", resp.StatusCode, http.StatusOK),
-					Repo:           r.String(),
-					Path:           p,
+				if err := resPkg.Validate(); err != nil {
+					helga_errors.HandleError(fmt.Errorf("validation failed for pkg fetched from artifactory api reason: %s", err.Error()))
+					return
 				}
 
-				helga_errors.HandleError(err)
-				panic(err)
-			}
-
-			var ar ArtifactoryResponse
-			if err := json.NewDecoder(resp.Body).Decode(&ar); err != nil {
-				err := helga_errors.ErrArtifactoryAPI{
-					DerivedFromErr: fmt.Errorf("couldn't parse response to struct for"),
-					Repo:           r.String(),
-					Path:           p,
+				// results are sorted by modification time so the first pkg picked in a path is already the latest by date
+				if _, seen := seenInPath[resPkg.Name()]; seen && !r.DecideByVersion {
+					return
 				}
 
-				helga_errors.HandleError(err)
-				panic(err)
-			}
-
-			for _, resPkg := range ar.Results {
-				if err := resPkg.Validate(); err != nil {
-					helga_errors.HandleError(fmt.Errorf("validation failed for pkg fetched from artifactory api reason: %s", err.Error()))
-					continue
+				if !accept(resPkg, r.VersionPolicy) {
+					return
 				}
 
+				seenInPath[resPkg.Name()] = struct{}{}
+
 				seenHelmPkg, exists := artifactoryHelmPackages[resPkg.Name()]
-				if exists {
-					pkg, err := DetermineNewerPkg(seenHelmPkg, resPkg, r.DecideByVersion)
-					if err != nil {
-						helga_errors.HandleError(err)
-						continue
-					}
-
-					artifactoryHelmPackages[resPkg.Name()] = pkg
-				} else {
+				if !exists {
 					artifactoryHelmPackages[resPkg.Name()] = resPkg
+					return
+				}
+
+				pkg, err := DetermineNewerPkg(seenHelmPkg, resPkg, r.DecideByVersion)
+				if err != nil {
+					helga_errors.HandleError(err)
+					return
 				}
+
+				artifactoryHelmPackages[resPkg.Name()] = pkg
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			if !complete {
+				incomplete = append(incomplete, helga_errors.ErrIncompleteListing{Repo: r.String(), Path: p, Fetched: a.MaxResults})
 			}
 		}
 	}
 
-	return artifactoryHelmPackages
+	return artifactoryHelmPackages, errors.Join(incomplete...)
+}
+
+func (a *Artifact) pageSize() uint {
+	if a.PageSize == 0 {
+		return vars.AQL_DEFAULT_PAGE_SIZE
+	}
+
+	return a.PageSize
+}
+
+// pages through the pkgs in a repo path newest first until the results or max_results are exhausted,
+// complete is false when max_results was reached while more pkgs may be left
+func (a *Artifact) queryRepoPath(ctx context.Context, client *http.Client, r *Repo, p string, handlePkg func(ArtifactHelmPackage)) (complete bool, err error) {
+	var fetched uint
+
+	for {
+		limit := a.pageSize()
+		if a.MaxResults > 0 && a.MaxResults-fetched < limit {
+			limit = a.MaxResults - fetched
+		}
+
+		pkgs, err := a.queryRepoPathPage(ctx, client, r, p, fetched, limit)
+		if err != nil {
+			return false, err
+		}
+
+		for _, pkg := range pkgs {
+			handlePkg(pkg)
+		}
+
+		fetched += uint(len(pkgs))
+
+		if uint(len(pkgs)) < limit {
+			return true, nil
+		}
+
+		if a.MaxResults > 0 && fetched >= a.MaxResults {
+			logger.GetLoggerInstance().Info(fmt.Sprintf("max results: %d reached for repo: %s, path: %s, the listing is incomplete", a.MaxResults, r.String(), p))
+			return false, nil
+		}
+	}
+}
+
+func (a *Artifact) queryRepoPathPage(ctx context.Context, client *http.Client, r *Repo, p string, offset, limit uint) ([]ArtifactHelmPackage, error) {
+	aqlQuery := `items.find(%s).include(%s).sort({"$desc": ["modified"]}).offset(%d).limit(%d)`
+
+	type ArtifactoryResponse struct {
+		Results []ArtifactHelmPackage `json:"results"`
+	}
+
+	logger.GetLoggerInstance().Info(fmt.Sprintf("sending request to fetch helm pkgs for repo: %s, path: %s, offset: %d, limit: %d", r.String(), p, offset, limit))
+
+	criteria, err := json.Marshal(r.aqlCriteria(p))
+	if err != nil {
+		return nil, helga_errors.ErrArtifactoryAPI{
+			DerivedFromErr: fmt.Errorf("generating aql criteria was unsuccesful: %w", err),
+			Repo:           r.String(),
+			Path:           p,
+		}
+	}
+
+	includes, err := json.Marshal(r.aqlIncludes())
+	if err != nil {
+		return nil, helga_errors.ErrArtifactoryAPI{
+			DerivedFromErr: fmt.Errorf("generating aql includes was unsuccesful: %w", err),
+			Repo:           r.String(),
+			Path:           p,
+		}
+	}
+
+	aqlIncludes := strings.TrimSuffix(strings.TrimPrefix(string(includes), "["), "]")
+
+	req, err := http.NewRequestWithContext(ctx, "POST", a.Domain+"/"+vars.AQL_ARTIFACT_PATH_POSTFIX, bytes.NewBufferString(fmt.Sprintf(aqlQuery, criteria, aqlIncludes, offset, limit)))
+	if err != nil {
+		return nil, helga_errors.ErrArtifactoryAPI{
+			DerivedFromErr: fmt.Errorf("generating request to the artifactory was unsuccesful"),
+			Repo:           r.String(),
+			Path:           p,
+		}
+	}
+
+	req.SetBasicAuth(a.Username, a.Password)
+	req.Header.Set("Content-Type", "text/plain")
+
+	started := time.Now()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		metrics.ObserveAQLRequest(r.String(), p, 0, started)
+
+		return nil, helga_errors.ErrArtifactoryAPI{
+			DerivedFromErr: fmt.Errorf("request to the artifactory was unsuccesful"),
+			Repo:           r.String(),
+			Path:           p,
+		}
+	}
+
+	defer resp.Body.Close()
+
+	metrics.ObserveAQLRequest(r.String(), p, resp.StatusCode, started)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, helga_errors.ErrArtifactoryAPI{
+			DerivedFromErr: fmt.Errorf("request to the artifactory was unsuccesful returned status code: %d, needs to be %d", resp.StatusCode, http.StatusOK),
+			Repo:           r.String(),
+			Path:           p,
+		}
+	}
+
+	var ar ArtifactoryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&ar); err != nil {
+		return nil, helga_errors.ErrArtifactoryAPI{
+			DerivedFromErr: fmt.Errorf("couldn't parse response to struct for"),
+			Repo:           r.String(),
+			Path:           p,
+		}
+	}
+
+	return ar.Results, nil
+}
+
+func (a *Artifact) GetLatestCharts(ctx context.Context, accept ChartFilter) (map[string]HelmChart, error) {
+	return a.GetChartPkgsInArtifact(ctx, accept)
+}
+
+func (a *Artifact) ResolveChartRef(chart HelmChart) (string, error) {
+	ahp, ok := chart.(ArtifactHelmPackage)
+	if !ok {
+		return "", fmt.Errorf("chart: %s was not fetched from artifact: %s", chart.Name(), a.String())
+	}
+
+	return a.Domain + "/" + ahp.Repo + "/" + ahp.Path + "/" + ahp.FullName, nil
+}
+
+func (a *Artifact) ComparesByVersion(chart HelmChart) bool {
+	ahp, ok := chart.(ArtifactHelmPackage)
+	if !ok {
+		return false
+	}
+
+	r := a.GetRepoByName(ahp.Repo)
+	if r == nil {
+		return false
+	}
+
+	return r.DecideByVersion
 }
 
 func (a *Artifact) GetRepoByName(repoName string) (repo *Repo) {
@@ -0,0 +1,196 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	helga_errors "github.com/fennet82/helga/pkg/errors"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// artifactory stand-in answering aql queries with pages of pkgs, which are already sorted newest first
+func newTestAQLServer(t *testing.T, pkgs []ArtifactHelmPackage) (*httptest.Server, *[][2]int) {
+	t.Helper()
+
+	var (
+		pages  [][2]int
+		pageRe = regexp.MustCompile(`\.offset\((\d+)\)\.limit\((\d+)\)$`)
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+
+		m := pageRe.FindStringSubmatch(string(body))
+		if m == nil {
+			t.Errorf("aql query without offset and limit: %s", body)
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		offset, _ := strconv.Atoi(m[1])
+		limit, _ := strconv.Atoi(m[2])
+		pages = append(pages, [2]int{offset, limit})
+
+		end := min(offset+limit, len(pkgs))
+		start := min(offset, end)
+
+		json.NewEncoder(w).Encode(map[string]any{"results": pkgs[start:end]})
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &pages
+}
+
+func testArtifactPkg(name, version string, age time.Duration) ArtifactHelmPackage {
+	return ArtifactHelmPackage{
+		Repo:         "helm",
+		Path:         "charts",
+		FullName:     fmt.Sprintf("%s-%s.tgz", name, version),
+		TimeModified: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC).Add(-age),
+		Properties: []ArtifactProperty{
+			{Key: chartNameProperty, Value: name},
+			{Key: chartVersionProperty, Value: version},
+		},
+	}
+}
+
+func acceptAll(HelmChart, *VersionPolicy) bool {
+	return true
+}
+
+func TestGetChartPkgsInArtifactPaging(t *testing.T) {
+	pkgs := []ArtifactHelmPackage{
+		testArtifactPkg("webapp", "1.3.0", 1*time.Hour),
+		testArtifactPkg("webapp", "1.2.0", 2*time.Hour),
+		testArtifactPkg("api", "2.0.0", 3*time.Hour),
+		testArtifactPkg("webapp", "1.1.0", 4*time.Hour),
+		testArtifactPkg("webapp", "1.0.0", 5*time.Hour),
+		testArtifactPkg("api", "1.9.0", 6*time.Hour),
+		testArtifactPkg("webapp", "2.0.0", 7*time.Hour),
+		testArtifactPkg("worker", "0.1.0", 8*time.Hour),
+	}
+
+	tests := []struct {
+		name            string
+		decideByVersion bool
+		maxResults      uint
+		wantPages       [][2]int
+		wantVersions    map[string]string
+		wantIncomplete  bool
+	}{
+		{
+			name:         "by date pages through every pkg",
+			wantPages:    [][2]int{{0, 2}, {2, 2}, {4, 2}, {6, 2}, {8, 2}},
+			wantVersions: map[string]string{"webapp": "1.3.0", "api": "2.0.0", "worker": "0.1.0"},
+		},
+		{
+			name:            "by version pages through every pkg",
+			decideByVersion: true,
+			wantPages:       [][2]int{{0, 2}, {2, 2}, {4, 2}, {6, 2}, {8, 2}},
+			wantVersions:    map[string]string{"webapp": "2.0.0", "api": "2.0.0", "worker": "0.1.0"},
+		},
+		{
+			name:            "max results bounds the last page",
+			decideByVersion: true,
+			maxResults:      3,
+			wantPages:       [][2]int{{0, 2}, {2, 1}},
+			wantVersions:    map[string]string{"webapp": "1.3.0", "api": "2.0.0"},
+			wantIncomplete:  true,
+		},
+		{
+			name:         "max results above the pkgs of the path",
+			maxResults:   20,
+			wantPages:    [][2]int{{0, 2}, {2, 2}, {4, 2}, {6, 2}, {8, 2}},
+			wantVersions: map[string]string{"webapp": "1.3.0", "api": "2.0.0", "worker": "0.1.0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, pages := newTestAQLServer(t, pkgs)
+
+			a := &Artifact{
+				Domain:     server.URL,
+				PageSize:   2,
+				MaxResults: tt.maxResults,
+				Repos:      []*Repo{{Name: "helm", DecideByVersion: tt.decideByVersion, Paths: []string{"charts"}}},
+			}
+
+			charts, err := a.GetChartPkgsInArtifact(context.Background(), acceptAll)
+
+			var incomplete helga_errors.ErrIncompleteListing
+			if errors.As(err, &incomplete) != tt.wantIncomplete || (err != nil && !tt.wantIncomplete) {
+				t.Fatalf("expected an incomplete listing: %t, got err: %v", tt.wantIncomplete, err)
+			}
+
+			if fmt.Sprint(*pages) != fmt.Sprint(tt.wantPages) {
+				t.Errorf("expected pages (offset, limit): %v, got: %v", tt.wantPages, *pages)
+			}
+
+			if len(charts) != len(tt.wantVersions) {
+				t.Errorf("expected charts: %v, got: %v", tt.wantVersions, charts)
+			}
+
+			for name, version := range tt.wantVersions {
+				if c, found := charts[name]; !found || c.Version() != version {
+					t.Errorf("expected chart: %s version: %s, got: %v", name, version, c)
+				}
+			}
+		})
+	}
+}
+
+func TestSyncHelmPackagesKeepsReleasesOfIncompleteListings(t *testing.T) {
+	server, _ := newTestAQLServer(t, []ArtifactHelmPackage{
+		testArtifactPkg("webapp", "1.3.0", 1*time.Hour),
+		testArtifactPkg("api", "2.0.0", 2*time.Hour),
+		testArtifactPkg("worker", "0.1.0", 3*time.Hour),
+	})
+
+	ns := &Namespace{
+		Name: "webapp",
+		Artifact: &Artifact{
+			Domain:     server.URL,
+			PageSize:   2,
+			MaxResults: 2,
+			Repos:      []*Repo{{Name: "helm", Paths: []string{"charts"}}},
+		},
+		Prune: &PrunePolicy{Mode: PruneModeOn},
+		helmClient: &fakeHelmClient{releases: []*release.Release{
+			testRelease("webapp", "webapp", "1.2.0", ownershipLabels()),
+			testRelease("worker", "worker", "0.1.0", ownershipLabels()),
+		}},
+	}
+
+	plan, err := ns.syncHelmPackages(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(plan.releasesToDelete) != 0 {
+		t.Errorf("expected nothing to prune after an incomplete listing, got: %s", releaseNames(plan.releasesToDelete))
+	}
+
+	reasons := make(map[string]string)
+	for _, e := range plan.entries {
+		reasons[e.Release] = e.Reason
+	}
+
+	if reasons["worker"] != PlanReasonSourcesIncomplete {
+		t.Errorf("expected release: worker reason: %q, got: %q", PlanReasonSourcesIncomplete, reasons["worker"])
+	}
+
+	if len(plan.chartsToDeploy) != 1 || plan.chartsToDeploy[0].Version() != "1.3.0" {
+		t.Errorf("expected the listed chart: webapp to still be upgraded, got: %+v", plan.chartsToDeploy)
+	}
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/fennet82/helga/internal/logger"
+	helga_errors "github.com/fennet82/helga/pkg/errors"
+)
+
+// per chart settings of a namespace
+type ChartConfig struct {
+	Name    string `yaml:"name"`
+	Version string `yaml:"version"` // semver constraint, e.g. "~1.4" or ">=2.0 <3.0"
+
+	ValuesOverlay `yaml:",inline"`
+
+	constraint *semver.Constraints
+}
+
+func (cc *ChartConfig) String() string {
+	return cc.Name
+}
+
+func (cc *ChartConfig) Validate() []error {
+	logger.GetLoggerInstance().Info(fmt.Sprintf("starting validation for chart config: %s", cc.String()))
+
+	var (
+		validationErrs []error
+		structName     = "ChartConfig"
+	)
+
+	if cc.Name == "" {
+		validationErrs = append(validationErrs, helga_errors.ErrValidation{StructName: structName, DerivedFromErr: errors.New("chart name cannot be empty")})
+	}
+
+	if cc.Version != "" {
+		constraint, err := semver.NewConstraint(cc.Version)
+		if err != nil {
+			validationErrs = append(validationErrs, helga_errors.ErrValidation{StructName: structName, DerivedFromErr: fmt.Errorf("version constraint: %s, of chart: %s is invalid, err: %w", cc.Version, cc.Name, err)})
+		} else {
+			cc.constraint = constraint
+		}
+	}
+
+	helga_errors.HandleErrors(validationErrs)
+
+	return validationErrs
+}
+
+func (cc *ChartConfig) AllowsVersion(version string) bool {
+	if cc.constraint == nil {
+		return true
+	}
+
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return false
+	}
+
+	return cc.constraint.Check(v)
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+func TestChartConfigAllowsVersion(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		want       bool
+	}{
+		{"", "3.0.0", true},
+		{"", "not-semver", true},
+		{"~1.4", "1.4.0", true},
+		{"~1.4", "1.4.9", true},
+		{"~1.4", "1.5.0", false},
+		{"^1.4", "1.9.2", true},
+		{"^1.4", "2.0.0", false},
+		{">=2.0 <3.0", "2.7.1", true},
+		{">=2.0 <3.0", "3.0.0", false},
+		{">=2.0 <3.0", "1.9.9", false},
+		{"~1.4", "not-semver", false},
+		// prereleases only satisfy constraints that mention a prerelease
+		{"~1.4", "1.4.1-rc.1", false},
+		{"~1.4.1-0", "1.4.1-rc.1", true},
+	}
+
+	for _, tt := range tests {
+		cc := &ChartConfig{Name: "webapp", Version: tt.constraint}
+		if errs := cc.Validate(); len(errs) > 0 {
+			t.Fatalf("constraint %q did not pass validation: %v", tt.constraint, errs)
+		}
+
+		if got := cc.AllowsVersion(tt.version); got != tt.want {
+			t.Errorf("constraint %q, AllowsVersion(%q) = %t, want %t", tt.constraint, tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestChartConfigRejectsInvalidConstraint(t *testing.T) {
+	cc := &ChartConfig{Name: "webapp", Version: "~>one"}
+
+	if errs := cc.Validate(); len(errs) != 1 {
+		t.Fatalf("expected one validation error for constraint: %s, got: %v", cc.Version, errs)
+	}
+}
+
+func TestNamespaceValidateRejectsInvalidConstraints(t *testing.T) {
+	tests := []struct {
+		version string
+		valid   bool
+	}{
+		{"~1.4", true},
+		{"", true},
+		{"~>one", false},
+		{"1.4.x.y", false},
+	}
+
+	for _, tt := range tests {
+		ns := &Namespace{
+			Name:             "webapp",
+			SyncInterval:     300,
+			LocalDirectories: []*LocalDirectory{newTestLocalDirectory(t, map[string][]string{"webapp": {"1.4.0"}})},
+			Charts:           []*ChartConfig{{Name: "webapp", Version: tt.version}},
+		}
+
+		if errs := ns.Validate(); (len(errs) == 0) != tt.valid {
+			t.Errorf("constraint: %q, expected namespace to be valid: %t, got: %v", tt.version, tt.valid, errs)
+		}
+
+		if len(ns.Charts) != 1 {
+			t.Errorf("constraint: %q, expected the chart config to be kept, got: %d", tt.version, len(ns.Charts))
+		}
+	}
+}
+
+func TestNamespaceAcceptChartAppliesConstraints(t *testing.T) {
+	cc := &ChartConfig{Name: "webapp", Version: "~1.4"}
+	cc.Validate()
+
+	ns := &Namespace{Name: "webapp", Charts: []*ChartConfig{cc}}
+
+	tests := []struct {
+		name    string
+		version string
+		want    bool
+	}{
+		{"webapp", "1.4.7", true},
+		{"webapp", "2.0.0", false},
+		{"api", "2.0.0", true},
+	}
+
+	for _, tt := range tests {
+		pkg := LocalHelmPackage{Metadata: &chart.Metadata{Name: tt.name, Version: tt.version}}
+
+		if got := ns.acceptChart(pkg, nil); got != tt.want {
+			t.Errorf("acceptChart(%s %s) = %t, want %t", tt.name, tt.version, got, tt.want)
+		}
+	}
+}
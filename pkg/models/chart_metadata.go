@@ -0,0 +1,237 @@
+package models
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/fennet82/helga/internal/logger"
+	"github.com/fennet82/helga/internal/vars"
+	helga_errors "github.com/fennet82/helga/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	chartNameProperty    = "chart.name"
+	chartVersionProperty = "chart.version"
+)
+
+// chart metadata by archive checksum, an archive with the same checksum always holds the same Chart.yaml.
+// the least recently used entries are evicted once the cache holds CHART_METADATA_CACHE_SIZE archives
+var (
+	chartMetadataCache   = make(map[string]*list.Element)
+	chartMetadataEntries = list.New()
+	chartMetadataMu      sync.Mutex
+)
+
+type chartMetadataEntry struct {
+	checksum string
+	metadata *chart.Metadata
+}
+
+func getCachedChartMetadata(checksum string) (*chart.Metadata, bool) {
+	chartMetadataMu.Lock()
+	defer chartMetadataMu.Unlock()
+
+	e, found := chartMetadataCache[checksum]
+	if !found {
+		return nil, false
+	}
+
+	chartMetadataEntries.MoveToFront(e)
+
+	return e.Value.(chartMetadataEntry).metadata, true
+}
+
+func cacheChartMetadata(checksum string, md *chart.Metadata) {
+	chartMetadataMu.Lock()
+	defer chartMetadataMu.Unlock()
+
+	if e, found := chartMetadataCache[checksum]; found {
+		e.Value = chartMetadataEntry{checksum: checksum, metadata: md}
+		chartMetadataEntries.MoveToFront(e)
+
+		return
+	}
+
+	chartMetadataCache[checksum] = chartMetadataEntries.PushFront(chartMetadataEntry{checksum: checksum, metadata: md})
+
+	for chartMetadataEntries.Len() > vars.CHART_METADATA_CACHE_SIZE {
+		oldest := chartMetadataEntries.Back()
+		chartMetadataEntries.Remove(oldest)
+		delete(chartMetadataCache, oldest.Value.(chartMetadataEntry).checksum)
+	}
+}
+
+// reports whether the archive is named "<chart>-<version>.tgz" after one of the charts, which lets older
+// archives of a chart that was already picked be skipped without downloading them to read their Chart.yaml
+func isArchiveOfCharts(fileName string, charts map[string]struct{}) bool {
+	base := strings.TrimSuffix(fileName, ".tgz")
+
+	for name := range charts {
+		version, found := strings.CutPrefix(base, name+"-")
+		if !found {
+			continue
+		}
+
+		if _, err := semver.StrictNewVersion(version); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// reads Chart.yaml from the root folder of a packaged chart without extracting the rest of the archive
+func readChartMetadataFromArchive(r io.Reader) (*chart.Metadata, error) {
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gzr.Close()
+
+	tr := tar.NewReader(gzr)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("archive has no Chart.yaml in its root folder")
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		dir, file := path.Split(strings.TrimPrefix(hdr.Name, "./"))
+		if file != "Chart.yaml" || strings.Count(dir, "/") != 1 {
+			continue
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+
+		md := &chart.Metadata{}
+		if err := yaml.Unmarshal(data, md); err != nil {
+			return nil, fmt.Errorf("couldn't parse Chart.yaml: %w", err)
+		}
+
+		return md, nil
+	}
+}
+
+func (a *Artifact) fetchChartMetadata(ctx context.Context, client *http.Client, pkg ArtifactHelmPackage) (*chart.Metadata, error) {
+	archiveURL := a.Domain + "/" + pkg.Repo + "/" + pkg.Path + "/" + pkg.FullName
+
+	logger.GetLoggerInstance().Info(fmt.Sprintf("fetching Chart.yaml from archive: %s", archiveURL))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", archiveURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.SetBasicAuth(a.Username, a.Password)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("request to the artifactory was unsuccesful returned status code: %d, needs to be %d", resp.StatusCode, http.StatusOK)
+	}
+
+	return readChartMetadataFromArchive(resp.Body)
+}
+
+func (a *Artifact) FetchValuesFile(ctx context.Context, chart HelmChart, fileName string) ([]byte, bool, error) {
+	ahp, ok := chart.(ArtifactHelmPackage)
+	if !ok {
+		return nil, false, fmt.Errorf("chart: %s was not fetched from artifact: %s", chart.Name(), a.String())
+	}
+
+	fileURL := a.Domain + "/" + ahp.Repo + "/" + ahp.Path + "/" + fileName
+
+	logger.GetLoggerInstance().Info(fmt.Sprintf("fetching values file: %s", fileURL))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fileURL, nil)
+	if err != nil {
+		return nil, false, err
+	}
+
+	req.SetBasicAuth(a.Username, a.Password)
+
+	resp, err := a.httpClient().Do(req)
+	if err != nil {
+		return nil, false, helga_errors.ErrArtifactoryAPI{DerivedFromErr: err, Repo: ahp.Repo, Path: ahp.Path}
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, false, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, helga_errors.ErrArtifactoryAPI{
+			DerivedFromErr: fmt.Errorf("request for values file: %s was unsuccesful returned status code: %d, needs to be %d", fileName, resp.StatusCode, http.StatusOK),
+			Repo:           ahp.Repo,
+			Path:           ahp.Path,
+		}
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, helga_errors.ErrArtifactoryAPI{DerivedFromErr: err, Repo: ahp.Repo, Path: ahp.Path}
+	}
+
+	return data, true, nil
+}
+
+// resolves the real chart name and version, from the helm properties artifactory sets on
+// helm repos or from the Chart.yaml inside the archive, cached by the archive checksum
+func (a *Artifact) resolveChartMetadata(ctx context.Context, client *http.Client, pkg *ArtifactHelmPackage) {
+	name, hasName := pkg.PropertyValue(chartNameProperty)
+	version, hasVersion := pkg.PropertyValue(chartVersionProperty)
+
+	if hasName && hasVersion {
+		pkg.Metadata = &chart.Metadata{Name: name, Version: version}
+		return
+	}
+
+	if pkg.Sha1 != "" {
+		if md, found := getCachedChartMetadata(pkg.Sha1); found {
+			pkg.Metadata = md
+			return
+		}
+	}
+
+	md, err := a.fetchChartMetadata(ctx, client, *pkg)
+	if err != nil {
+		helga_errors.HandleError(helga_errors.ErrArtifactoryAPI{
+			DerivedFromErr: fmt.Errorf("couldn't resolve chart metadata for: %s, falling back to the filename, err: %w", pkg.FullName, err),
+			Repo:           pkg.Repo,
+			Path:           pkg.Path,
+		})
+
+		return
+	}
+
+	if pkg.Sha1 != "" {
+		cacheChartMetadata(pkg.Sha1, md)
+	}
+
+	pkg.Metadata = md
+}
@@ -0,0 +1,174 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fennet82/helga/internal/vars"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+func TestIsArchiveOfCharts(t *testing.T) {
+	charts := map[string]struct{}{"webapp": {}, "app": {}}
+
+	tests := []struct {
+		fileName string
+		want     bool
+	}{
+		{"webapp-1.2.3.tgz", true},
+		{"webapp-1.2.3-rc.1.tgz", true},
+		{"webapp-1.2.3+build.4.tgz", true},
+		{"webapp-api-1.2.3.tgz", false},
+		{"app-v2-1.0.0.tgz", false},
+		{"app-2-1.0.0.tgz", false},
+		{"webapp-latest.tgz", false},
+		{"worker-1.0.0.tgz", false},
+	}
+
+	for _, tt := range tests {
+		if got := isArchiveOfCharts(tt.fileName, charts); got != tt.want {
+			t.Errorf("isArchiveOfCharts(%q) = %t, want %t", tt.fileName, got, tt.want)
+		}
+	}
+}
+
+func TestChartMetadataCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	checksum := func(i int) string {
+		return fmt.Sprintf("eviction-test-%d", i)
+	}
+
+	for i := 0; i < vars.CHART_METADATA_CACHE_SIZE; i++ {
+		cacheChartMetadata(checksum(i), &chart.Metadata{Name: "webapp", Version: fmt.Sprintf("1.0.%d", i)})
+	}
+
+	// the first archive is used again so the second one is the least recently used
+	if _, found := getCachedChartMetadata(checksum(0)); !found {
+		t.Fatalf("expected %s to be cached", checksum(0))
+	}
+
+	cacheChartMetadata(checksum(vars.CHART_METADATA_CACHE_SIZE), &chart.Metadata{Name: "webapp", Version: "2.0.0"})
+
+	if chartMetadataEntries.Len() != vars.CHART_METADATA_CACHE_SIZE || len(chartMetadataCache) != vars.CHART_METADATA_CACHE_SIZE {
+		t.Fatalf("expected cache to hold %d archives, got: %d", vars.CHART_METADATA_CACHE_SIZE, chartMetadataEntries.Len())
+	}
+
+	if _, found := getCachedChartMetadata(checksum(1)); found {
+		t.Errorf("expected %s to be evicted", checksum(1))
+	}
+
+	for _, c := range []string{checksum(0), checksum(2), checksum(vars.CHART_METADATA_CACHE_SIZE)} {
+		if _, found := getCachedChartMetadata(c); !found {
+			t.Errorf("expected %s to be cached", c)
+		}
+	}
+}
+
+func TestGetChartPkgsInArtifactOnlyDownloadsCandidates(t *testing.T) {
+	pkgs := []ArtifactHelmPackage{
+		{Repo: "helm", Path: "charts", FullName: "webapp-1.3.0.tgz", Sha1: "webapp-130"},
+		{Repo: "helm", Path: "charts", FullName: "webapp-1.2.0.tgz", Sha1: "webapp-120"},
+		{Repo: "helm", Path: "charts", FullName: "api-2.0.0-rc.1.tgz", Sha1: "api-200-rc1"},
+		{Repo: "helm", Path: "charts", FullName: "webapp-1.1.0.tgz", Sha1: "webapp-110"},
+	}
+
+	for i := range pkgs {
+		pkgs[i].TimeModified = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC).Add(-time.Duration(i) * time.Hour)
+	}
+
+	var downloads []string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/search/aql", func(w http.ResponseWriter, _ *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"results": pkgs})
+	})
+	mux.HandleFunc("GET /helm/charts/{file}", func(w http.ResponseWriter, req *http.Request) {
+		downloads = append(downloads, req.PathValue("file"))
+
+		base := strings.TrimSuffix(req.PathValue("file"), ".tgz")
+		name, version, _ := strings.Cut(base, "-")
+
+		archive, err := chartutil.Save(&chart.Chart{Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: name, Version: version}}, t.TempDir())
+		if err != nil {
+			t.Errorf("packaging chart: %v", err)
+			return
+		}
+
+		data, _ := os.ReadFile(archive)
+		w.Write(data)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	a := &Artifact{Domain: server.URL, Repos: []*Repo{{Name: "helm", Paths: []string{"charts"}}}}
+
+	charts, err := a.GetChartPkgsInArtifact(context.Background(), acceptAll)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := charts["api"]; got == nil || got.Version() != "2.0.0-rc.1" {
+		t.Errorf("expected api version 2.0.0-rc.1 read from its Chart.yaml, got: %v", got)
+	}
+
+	if got := charts["webapp"]; got == nil || got.Version() != "1.3.0" {
+		t.Errorf("expected webapp version 1.3.0, got: %v", got)
+	}
+
+	if want := []string{"webapp-1.3.0.tgz", "api-2.0.0-rc.1.tgz"}; strings.Join(downloads, ",") != strings.Join(want, ",") {
+		t.Errorf("expected downloads: %v, got: %v", want, downloads)
+	}
+
+	// a second pass reads the metadata of the picked archives from the cache
+	downloads = nil
+
+	if _, err := a.GetChartPkgsInArtifact(context.Background(), acceptAll); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(downloads) != 0 {
+		t.Errorf("expected no downloads for cached archives, got: %v", downloads)
+	}
+
+}
+
+func TestArtifactFetchValuesFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if user, pass, _ := req.BasicAuth(); user != "helga" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if req.URL.Path != "/helm/charts/values-prod.yaml" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Write([]byte("replicas: 3\n"))
+	}))
+	t.Cleanup(server.Close)
+
+	a := &Artifact{Domain: server.URL, Username: "helga", Password: "secret"}
+	pkg := ArtifactHelmPackage{Repo: "helm", Path: "charts", FullName: "webapp-1.0.0.tgz"}
+
+	data, found, err := a.FetchValuesFile(context.Background(), pkg, "values-prod.yaml")
+	if err != nil || !found || string(data) != "replicas: 3\n" {
+		t.Errorf("expected values file, got: %q found: %t err: %v", data, found, err)
+	}
+
+	if _, found, err := a.FetchValuesFile(context.Background(), pkg, "values-dev.yaml"); err != nil || found {
+		t.Errorf("expected missing values file to be reported as not found, got found: %t err: %v", found, err)
+	}
+
+	if a.httpClient().Timeout == 0 {
+		t.Error("expected the artifactory client to have a timeout")
+	}
+}
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"context"
+	"errors"
+
+	"github.com/fennet82/helga/internal/utils"
+	helga_errors "github.com/fennet82/helga/pkg/errors"
+)
+
+// decides whether a chart may be picked as the latest chart of a source, policy is the
+// version policy of the repo the chart was found in or nil if the repo has none
+type ChartFilter func(chart HelmChart, policy *VersionPolicy) bool
+
+// registry of helm charts a namespace can be synced from
+type ChartSource interface {
+	utils.Validatable
+
+	// returns the newest chart accepted by the filter for every chart name found in the source
+	GetLatestCharts(ctx context.Context, accept ChartFilter) (map[string]HelmChart, error)
+	// returns the chart reference helm should install the chart from
+	ResolveChartRef(chart HelmChart) (string, error)
+	// reports whether charts of the source are compared by version or by time
+	ComparesByVersion(chart HelmChart) bool
+}
+
+// implemented by chart sources that serve values files published next to their charts,
+// found is false when the chart has no such file
+type ValuesFileSource interface {
+	FetchValuesFile(ctx context.Context, chart HelmChart, fileName string) (data []byte, found bool, err error)
+}
+
+// helm chart paired with the source it was fetched from
+type SourcedChart struct {
+	HelmChart
+	Source ChartSource
+}
+
+func (sc SourcedChart) ResolveChartRef() (string, error) {
+	return sc.Source.ResolveChartRef(sc.HelmChart)
+}
+
+func (sc SourcedChart) ComparesByVersion() bool {
+	return sc.Source.ComparesByVersion(sc.HelmChart)
+}
+
+// merges the latest charts of every source into one map keeping the newer chart on name conflicts
+func GetLatestChartsFromSources(ctx context.Context, sources []ChartSource, accept ChartFilter) (map[string]SourcedChart, []error) {
+	var (
+		errs   []error
+		latest = make(map[string]SourcedChart)
+	)
+
+	for _, s := range sources {
+		charts, err := s.GetLatestCharts(ctx, accept)
+		if err != nil {
+			errs = append(errs, err)
+
+			// the charts of a listing cut short are still deployed, the error only keeps them from being pruned
+			var incomplete helga_errors.ErrIncompleteListing
+			if !errors.As(err, &incomplete) {
+				continue
+			}
+		}
+
+		for name, c := range charts {
+			candidate := SourcedChart{HelmChart: c, Source: s}
+
+			seen, exists := latest[name]
+			if !exists {
+				latest[name] = candidate
+				continue
+			}
+
+			pkg, err := DetermineNewerPkg(seen, candidate, candidate.ComparesByVersion())
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+
+			latest[name] = pkg.(SourcedChart)
+		}
+	}
+
+	return latest, errs
+}
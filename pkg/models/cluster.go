@@ -3,8 +3,8 @@ package models
 import (
 	"errors"
 	"fmt"
+	"path/filepath"
 	"regexp"
-	"sync"
 
 	"github.com/fennet82/helga/internal/logger"
 	"github.com/fennet82/helga/internal/utils"
@@ -23,6 +23,8 @@ type Cluster struct {
 	InsecureSkipTLSVerify bool         `yaml:"insecure_skip_tls_verify"`
 	CACertFilePath        string       `yaml:"ca_cert_file_path"`
 	Namespaces            []*Namespace `yaml:"namespaces"`
+
+	ValuesOverlay `yaml:",inline"`
 }
 
 func (c *Cluster) String() string {
@@ -179,7 +181,9 @@ func (c *Cluster) generateKubeConfig() ([]byte, error) {
 	return yamlData, nil
 }
 
-func (c *Cluster) initiateHelmClientByNamespace(nsName string) (helmclient.Client, error) {
+// helm repositories, their cache and registry logins are kept under helmHome when set,
+// otherwise the repositories of every namespace share helga's defaults
+func (c *Cluster) initiateHelmClientByNamespace(nsName, helmHome string) (helmclient.Client, error) {
 	kconf, err := c.generateKubeConfig()
 	if err != nil {
 		return nil, helga_errors.ErrHelmClient{ErrMsg: fmt.Sprintf("error generating kubeconf for cluster: %s\n derived from err: %s", c.Name, err.Error())}
@@ -199,6 +203,12 @@ func (c *Cluster) initiateHelmClientByNamespace(nsName string) (helmclient.Clien
 		KubeContext: fmt.Sprintf("%s-%s", c.Name, nsName),
 	}
 
+	if helmHome != "" {
+		options.Options.RepositoryCache = filepath.Join(helmHome, "cache")
+		options.Options.RepositoryConfig = filepath.Join(helmHome, "repositories.yaml")
+		options.Options.RegistryConfig = filepath.Join(helmHome, "registry.json")
+	}
+
 	hc, err := helmclient.NewClientFromKubeConf(options)
 	if err != nil {
 		return nil, helga_errors.ErrHelmClient{ErrMsg: fmt.Sprintf("error getting helmClient for cluster: %s\n derived from err: %s", c.Name, err.Error())}
@@ -207,34 +217,34 @@ func (c *Cluster) initiateHelmClientByNamespace(nsName string) (helmclient.Clien
 	return hc, nil
 }
 
-func (c *Cluster) Init() {
-	for i, ns := range c.Namespaces {
-		logger.GetLoggerInstance().Info(fmt.Sprintf("starting initalization for namespace: %s", ns.Name))
+func (c *Cluster) InitNamespace(ns *Namespace) error {
+	return c.initNamespace(ns, "")
+}
 
-		hc, err := c.initiateHelmClientByNamespace(ns.Name)
-		if err != nil {
-			helga_errors.HandleError(fmt.Errorf("err occured while initaiting client for namespace: %s, removing from targets, derived from err: %w", ns.String(), err))
-			c.Namespaces = append(c.Namespaces[:i], c.Namespaces[i+1:]...)
+// initiates the namespace with helm repositories, their cache and registry logins kept under helmHome,
+// for commands that only read so they can render diffs without touching the helm configuration of the host
+func (c *Cluster) InitNamespaceInHelmHome(ns *Namespace, helmHome string) error {
+	return c.initNamespace(ns, helmHome)
+}
 
-			continue
-		}
+func (c *Cluster) initNamespace(ns *Namespace, helmHome string) error {
+	logger.GetLoggerInstance().Info(fmt.Sprintf("starting initalization for namespace: %s", ns.Name))
 
-		ns.helmClient = hc
-		ns.addOrUpdateHelmRepos()
+	hc, err := c.initiateHelmClientByNamespace(ns.Name, helmHome)
+	if err != nil {
+		return err
 	}
-}
-
-func (c *Cluster) SyncNamespacesWithCluster() {
-	var wg sync.WaitGroup
 
-	for _, ns := range c.Namespaces {
-		wg.Add(1)
+	ns.helmClient = hc
+	ns.clusterName = c.Name
 
-		go func() {
-			defer wg.Done()
-			ns.SyncHelmPkgsWithCluster()
-		}()
+	if err := ns.configureOCIPulls(); err != nil {
+		return err
 	}
 
-	wg.Wait()
+	ns.addOrUpdateHelmRepos()
+	ns.addOrUpdateHelmRepositories()
+	ns.loginOCIRegistries()
+
+	return nil
 }
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// plan and status add repositories inside their own helm home so the one of the host stays untouched
+func TestInitNamespaceInHelmHomeAddsRepositoriesThere(t *testing.T) {
+	server := newTestIndexServer(t, false)
+	helmHome := t.TempDir()
+
+	cl := &Cluster{Name: "cluster-1", Server: "https://127.0.0.1:6443", Username: "helga", Token: "token", InsecureSkipTLSVerify: true}
+	ns := &Namespace{Name: "web", HelmRepositories: []*HelmRepository{{Name: "charts", URL: server.URL, DecideByVersion: true}}}
+
+	if err := cl.InitNamespaceInHelmHome(ns, helmHome); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(ns.HelmRepositories) != 1 {
+		t.Fatalf("expected repository: charts to be added, got: %v", ns.HelmRepositories)
+	}
+
+	repos, err := os.ReadFile(filepath.Join(helmHome, "repositories.yaml"))
+	if err != nil {
+		t.Fatalf("expected the repository config inside the helm home: %v", err)
+	}
+
+	if !strings.Contains(string(repos), server.URL) {
+		t.Errorf("expected repository: charts in the repository config, got: %s", repos)
+	}
+
+	if _, err := os.Stat(filepath.Join(helmHome, "cache", "charts-index.yaml")); err != nil {
+		t.Errorf("expected the index of repository: charts cached inside the helm home: %v", err)
+	}
+
+	if settings := ns.helmClient.GetSettings(); settings.RegistryConfig != filepath.Join(helmHome, "registry.json") {
+		t.Errorf("expected registry logins inside the helm home, got: %s", settings.RegistryConfig)
+	}
+}
@@ -2,12 +2,15 @@ package models
 
 import (
 	"fmt"
+	"path"
 	"strings"
 	"time"
 
+	"github.com/Masterminds/semver/v3"
 	helga_errors "github.com/fennet82/helga/pkg/errors"
-	"golang.org/x/mod/semver"
+	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/repo"
 )
 
 type HelmChart interface {
@@ -19,10 +22,39 @@ type HelmChart interface {
 
 // helm chart fetched from namespace by go-helm-client
 type ArtifactHelmPackage struct {
-	Repo         string    `yaml:"repo"`
-	Path         string    `yaml:"path"`
-	FullName     string    `json:"name"`
-	TimeModified time.Time `json:"modified"`
+	Repo         string             `yaml:"repo"`
+	Path         string             `yaml:"path"`
+	FullName     string             `json:"name"`
+	TimeModified time.Time          `json:"modified"`
+	Sha1         string             `json:"actual_sha1"`
+	Properties   []ArtifactProperty `json:"properties"`
+	// resolved from the helm properties of the item or the Chart.yaml inside the archive
+	Metadata *chart.Metadata `json:"-"`
+}
+
+type ArtifactProperty struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+func (ahp ArtifactHelmPackage) HasProperty(key, value string) bool {
+	for _, p := range ahp.Properties {
+		if p.Key == key && p.Value == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (ahp ArtifactHelmPackage) PropertyValue(key string) (string, bool) {
+	for _, p := range ahp.Properties {
+		if p.Key == key {
+			return p.Value, true
+		}
+	}
+
+	return "", false
 }
 
 func (ahp ArtifactHelmPackage) Validate() error {
@@ -35,7 +67,12 @@ func (ahp ArtifactHelmPackage) Validate() error {
 	return nil
 }
 
+// falls back to splitting the filename when the chart metadata could not be resolved
 func (ahp ArtifactHelmPackage) Name() string {
+	if ahp.Metadata != nil && ahp.Metadata.Name != "" {
+		return ahp.Metadata.Name
+	}
+
 	s := strings.Split(strings.TrimSuffix(ahp.FullName, ".tgz"), "-")
 	pkgName := strings.Join(s[:len(s)-1], "-")
 
@@ -43,6 +80,10 @@ func (ahp ArtifactHelmPackage) Name() string {
 }
 
 func (ahp ArtifactHelmPackage) Version() string {
+	if ahp.Metadata != nil && ahp.Metadata.Version != "" {
+		return ahp.Metadata.Version
+	}
+
 	s := strings.Split(strings.TrimSuffix(ahp.FullName, ".tgz"), "-")
 	pkgVersion := s[len(s)-1]
 
@@ -53,6 +94,88 @@ func (hri ArtifactHelmPackage) Time() time.Time {
 	return hri.TimeModified
 }
 
+// helm chart fetched from an oci registry, the tag is the chart version
+type OCIHelmPackage struct {
+	Registry   string
+	Repository string
+	Tag        string
+}
+
+func (ohp OCIHelmPackage) Validate() error {
+	if _, err := semver.StrictNewVersion(ohp.Version()); err != nil {
+		return fmt.Errorf("package: %s tag: %s is not a valid chart version", ohp.Repository, ohp.Tag)
+	}
+
+	return nil
+}
+
+func (ohp OCIHelmPackage) Name() string {
+	return path.Base(ohp.Repository)
+}
+
+// helm pushes "+" as "_" since "+" is not allowed in oci tags
+func (ohp OCIHelmPackage) Version() string {
+	return strings.ReplaceAll(ohp.Tag, "_", "+")
+}
+
+// oci tags carry no time, OCIRegistry.ComparesByVersion makes sure charts are compared by semver
+func (ohp OCIHelmPackage) Time() time.Time {
+	return time.Time{}
+}
+
+// helm chart listed in the index.yaml of a helm repository
+type IndexHelmPackage struct {
+	Repo string
+	*repo.ChartVersion
+}
+
+func (ihp IndexHelmPackage) Validate() error {
+	if ihp.ChartVersion == nil || ihp.Metadata == nil || ihp.Metadata.Name == "" || ihp.Metadata.Version == "" {
+		return fmt.Errorf("package: %+v from repo: %s is missing name or version", ihp.ChartVersion, ihp.Repo)
+	}
+
+	return nil
+}
+
+func (ihp IndexHelmPackage) Name() string {
+	return ihp.Metadata.Name
+}
+
+func (ihp IndexHelmPackage) Version() string {
+	return ihp.Metadata.Version
+}
+
+func (ihp IndexHelmPackage) Time() time.Time {
+	return ihp.Created
+}
+
+// packaged helm chart on the local filesystem, name and version come from its Chart.yaml
+type LocalHelmPackage struct {
+	FilePath     string
+	Metadata     *chart.Metadata
+	TimeModified time.Time
+}
+
+func (lhp LocalHelmPackage) Validate() error {
+	if lhp.Metadata == nil || lhp.Metadata.Name == "" || lhp.Metadata.Version == "" {
+		return fmt.Errorf("package: %s is missing name or version in its Chart.yaml", lhp.FilePath)
+	}
+
+	return nil
+}
+
+func (lhp LocalHelmPackage) Name() string {
+	return lhp.Metadata.Name
+}
+
+func (lhp LocalHelmPackage) Version() string {
+	return lhp.Metadata.Version
+}
+
+func (lhp LocalHelmPackage) Time() time.Time {
+	return lhp.TimeModified
+}
+
 // helm chart fetched from namespace by go-helm-client
 type HelmReleaseInfo struct {
 	release.Release
@@ -74,28 +197,62 @@ func (hri HelmReleaseInfo) Time() time.Time {
 	return hri.Info.LastDeployed.Time
 }
 
+// reasons a pkg was picked over another one by DetermineNewerPkgWithReason
+const (
+	SelectionReasonVersion           = "version"
+	SelectionReasonTime              = "time"
+	SelectionReasonEqualVersions     = "time (equal versions)"
+	SelectionReasonNonSemverFallback = "time (non semver fallback)"
+)
+
 func DetermineNewerPkg(pkgA HelmChart, pkgB HelmChart, decideByVersion bool) (HelmChart, error) {
+	pkg, _, err := DetermineNewerPkgWithReason(pkgA, pkgB, decideByVersion)
+
+	return pkg, err
+}
+
+// versions are compared with helm's semver semantics, pkgs whose versions are equal
+// (e.g. differ only in build metadata) or not valid semver are compared by time
+func DetermineNewerPkgWithReason(pkgA HelmChart, pkgB HelmChart, decideByVersion bool) (HelmChart, string, error) {
 	if err := pkgA.Validate(); err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	if err := pkgB.Validate(); err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	retPkg := pkgB
-
 	if pkgA.Name() != pkgB.Name() {
-		return nil, &helga_errors.ErrPkgsDoNotMatch{
+		return nil, "", &helga_errors.ErrPkgsDoNotMatch{
 			ErrMsg: fmt.Sprintf("pkg's names: %s and %s do not match", pkgA.Name(), pkgB.Name()),
 		}
 	}
 
-	if decideByVersion && semver.Compare("v"+pkgA.Version(), "v"+pkgB.Version()) > 0 {
-		retPkg = pkgA
-	} else if pkgA.Time().After(pkgB.Time()) {
-		retPkg = pkgA
+	newerByTime := func(reason string) (HelmChart, string, error) {
+		if pkgA.Time().After(pkgB.Time()) {
+			return pkgA, reason, nil
+		}
+
+		return pkgB, reason, nil
+	}
+
+	if !decideByVersion {
+		return newerByTime(SelectionReasonTime)
 	}
 
-	return retPkg, nil
+	versionA, errA := semver.NewVersion(pkgA.Version())
+	versionB, errB := semver.NewVersion(pkgB.Version())
+
+	if errA != nil || errB != nil {
+		return newerByTime(SelectionReasonNonSemverFallback)
+	}
+
+	switch versionA.Compare(versionB) {
+	case 1:
+		return pkgA, SelectionReasonVersion, nil
+	case -1:
+		return pkgB, SelectionReasonVersion, nil
+	default:
+		return newerByTime(SelectionReasonEqualVersions)
+	}
 }
@@ -0,0 +1,208 @@
+package models
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fennet82/helga/internal/logger"
+	"github.com/fennet82/helga/internal/vars"
+	helga_errors "github.com/fennet82/helga/pkg/errors"
+	"helm.sh/helm/v3/pkg/repo"
+	"sigs.k8s.io/yaml"
+)
+
+// classic helm http repository (chartmuseum, static index.yaml hosting)
+type HelmRepository struct {
+	Name            string         `yaml:"name"`
+	URL             string         `yaml:"url"`
+	Username        string         `yaml:"username,omitempty"`
+	Password        string         `yaml:"password,omitempty"`
+	DecideByVersion bool           `yaml:"decideByVersion"` // will decide by date if not true
+	Charts          []string       `yaml:"charts"`          // chart name patterns, every chart is synced if empty
+	VersionPolicy   *VersionPolicy `yaml:"version_policy"`
+
+	InsecureSkipTLSVerify bool `yaml:"insecure_skip_tls_verify,omitempty"`
+
+	// shared by every request to the repository, created on first use
+	client     *http.Client
+	clientOnce sync.Once
+}
+
+func (h *HelmRepository) String() string {
+	return h.Name
+}
+
+func (h *HelmRepository) Validate() []error {
+	logger.GetLoggerInstance().Info(fmt.Sprintf("starting validation for helm repository: %s", h.String()))
+
+	var (
+		validationErrs []error
+		structName     = "HelmRepository"
+	)
+
+	if h.Name == "" {
+		validationErrs = append(validationErrs, helga_errors.ErrValidation{StructName: structName, DerivedFromErr: errors.New("helm repository name cannot be empty")})
+	}
+
+	if u, err := url.Parse(h.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		validationErrs = append(validationErrs, helga_errors.ErrValidation{StructName: structName, DerivedFromErr: fmt.Errorf("url: %s, is not a valid http(s) url", h.URL)})
+	}
+
+	for _, pattern := range h.Charts {
+		if _, err := path.Match(pattern, ""); err != nil {
+			validationErrs = append(validationErrs, helga_errors.ErrValidation{StructName: structName, DerivedFromErr: fmt.Errorf("chart pattern: %s, is malformed", pattern)})
+		}
+	}
+
+	if h.VersionPolicy != nil && len(h.VersionPolicy.Validate()) > 0 {
+		validationErrs = append(validationErrs, helga_errors.ErrValidation{StructName: structName, DerivedFromErr: fmt.Errorf("version policy of helm repository: %s is invalid", h.Name)})
+	}
+
+	helga_errors.HandleErrors(validationErrs)
+
+	return validationErrs
+}
+
+func (h *HelmRepository) GetAsHelmRepoEntry() *repo.Entry {
+	e := (&Repo{Name: h.Name}).GetAsHelmRepoEntry()
+
+	e.URL = h.URL
+	e.Username = h.Username
+	e.Password = h.Password
+	e.InsecureSkipTLSverify = h.InsecureSkipTLSVerify
+
+	return e
+}
+
+func (h *HelmRepository) httpClient() *http.Client {
+	h.clientOnce.Do(func() {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: h.InsecureSkipTLSVerify}
+
+		h.client = &http.Client{Timeout: vars.HELM_REPOSITORY_REQUEST_TIMEOUT * time.Second, Transport: transport}
+	})
+
+	return h.client
+}
+
+func (h *HelmRepository) matchesChart(chartName string) bool {
+	if len(h.Charts) == 0 {
+		return true
+	}
+
+	for _, pattern := range h.Charts {
+		if matched, _ := path.Match(pattern, chartName); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (h *HelmRepository) fetchIndexFile(ctx context.Context) (*repo.IndexFile, error) {
+	indexURL := strings.TrimSuffix(h.URL, "/") + "/index.yaml"
+
+	logger.GetLoggerInstance().Info(fmt.Sprintf("sending request to fetch index for helm repository: %s, url: %s", h.String(), indexURL))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", indexURL, nil)
+	if err != nil {
+		return nil, helga_errors.ErrHelmRepositoryAPI{DerivedFromErr: err, Repo: h.String(), URL: indexURL}
+	}
+
+	if h.Username != "" {
+		req.SetBasicAuth(h.Username, h.Password)
+	}
+
+	resp, err := h.httpClient().Do(req)
+	if err != nil {
+		return nil, helga_errors.ErrHelmRepositoryAPI{DerivedFromErr: err, Repo: h.String(), URL: indexURL}
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, helga_errors.ErrHelmRepositoryAPI{
+			DerivedFromErr: fmt.Errorf("returned status code: %d, needs to be %d", resp.StatusCode, http.StatusOK),
+			Repo:           h.String(),
+			URL:            indexURL,
+		}
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, helga_errors.ErrHelmRepositoryAPI{DerivedFromErr: err, Repo: h.String(), URL: indexURL}
+	}
+
+	index := repo.NewIndexFile()
+	if err := yaml.Unmarshal(data, index); err != nil {
+		return nil, helga_errors.ErrHelmRepositoryAPI{DerivedFromErr: fmt.Errorf("couldn't parse index file: %w", err), Repo: h.String(), URL: indexURL}
+	}
+
+	return index, nil
+}
+
+func (h *HelmRepository) GetLatestCharts(ctx context.Context, accept ChartFilter) (map[string]HelmChart, error) {
+	index, err := h.fetchIndexFile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	indexHelmPackages := make(map[string]HelmChart)
+
+	for chartName, chartVersions := range index.Entries {
+		if !h.matchesChart(chartName) {
+			continue
+		}
+
+		for _, cv := range chartVersions {
+			pkg := IndexHelmPackage{Repo: h.Name, ChartVersion: cv}
+			if err := pkg.Validate(); err != nil {
+				helga_errors.HandleError(fmt.Errorf("validation failed for pkg fetched from helm repository: %s reason: %s", h.String(), err.Error()))
+				continue
+			}
+
+			if !accept(pkg, h.VersionPolicy) {
+				continue
+			}
+
+			seenHelmPkg, exists := indexHelmPackages[pkg.Name()]
+			if exists {
+				newer, err := DetermineNewerPkg(seenHelmPkg, pkg, h.DecideByVersion)
+				if err != nil {
+					helga_errors.HandleError(err)
+					continue
+				}
+
+				indexHelmPackages[pkg.Name()] = newer
+			} else {
+				indexHelmPackages[pkg.Name()] = pkg
+			}
+		}
+	}
+
+	return indexHelmPackages, nil
+}
+
+// charts are installed through the repo registered in the helm client under the repository name,
+// its cached index is refreshed before every sync cycle deploying from it
+func (h *HelmRepository) ResolveChartRef(chart HelmChart) (string, error) {
+	ihp, ok := chart.(IndexHelmPackage)
+	if !ok {
+		return "", fmt.Errorf("chart: %s was not fetched from helm repository: %s", chart.Name(), h.String())
+	}
+
+	return ihp.Repo + "/" + ihp.Name(), nil
+}
+
+func (h *HelmRepository) ComparesByVersion(_ HelmChart) bool {
+	return h.DecideByVersion
+}
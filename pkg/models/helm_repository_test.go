@@ -0,0 +1,105 @@
+package models
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/release"
+)
+
+const testIndexFile = `apiVersion: v1
+entries:
+  nginx:
+    - name: nginx
+      version: 1.1.0
+      urls: [charts/nginx-1.1.0.tgz]
+    - name: nginx
+      version: 1.0.0
+      urls: [charts/nginx-1.0.0.tgz]
+`
+
+func newTestIndexServer(t *testing.T, tls bool) *httptest.Server {
+	t.Helper()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/index.yaml" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Write([]byte(testIndexFile))
+	})
+
+	server := httptest.NewUnstartedServer(handler)
+	if tls {
+		server.StartTLS()
+	} else {
+		server.Start()
+	}
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestHelmRepositoryTLSVerification(t *testing.T) {
+	server := newTestIndexServer(t, true)
+
+	tests := []struct {
+		insecure bool
+		wantErr  bool
+	}{
+		{insecure: false, wantErr: true},
+		{insecure: true, wantErr: false},
+	}
+
+	for _, tt := range tests {
+		h := &HelmRepository{Name: "charts", URL: server.URL, DecideByVersion: true, InsecureSkipTLSVerify: tt.insecure}
+
+		if e := h.GetAsHelmRepoEntry(); e.InsecureSkipTLSverify != tt.insecure {
+			t.Errorf("insecure: %t, expected the repo entry to skip tls verification: %t", tt.insecure, tt.insecure)
+		}
+
+		charts, err := h.GetLatestCharts(context.Background(), acceptAll)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("insecure: %t, expected an error: %t, got: %v", tt.insecure, tt.wantErr, err)
+		}
+
+		if !tt.wantErr && charts["nginx"].Version() != "1.1.0" {
+			t.Errorf("expected nginx 1.1.0, got: %v", charts["nginx"])
+		}
+	}
+}
+
+func TestSyncCycleRefreshesHelmRepositoriesItDeploysFrom(t *testing.T) {
+	server := newTestIndexServer(t, false)
+
+	hc := &deployingHelmClient{fakeHelmClient: fakeHelmClient{releases: []*release.Release{
+		testRelease("nginx", "nginx", "1.0.0", ownershipLabels()),
+	}}}
+
+	ns := &Namespace{
+		Name: "web",
+		HelmRepositories: []*HelmRepository{
+			{Name: "charts", URL: server.URL, DecideByVersion: true},
+			{Name: "unused", URL: server.URL, DecideByVersion: true, Charts: []string{"redis"}},
+		},
+		helmClient: hc,
+	}
+
+	for cycle := 1; cycle <= 2; cycle++ {
+		if err := ns.syncCycle(context.Background(), context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(hc.repos) != cycle || hc.repos[cycle-1] != "charts" {
+			t.Fatalf("expected repository: charts to be refreshed once in cycle: %d, got: %v", cycle, hc.repos)
+		}
+	}
+
+	if len(hc.specs) != 2 || hc.specs[0].ChartName != "charts/nginx" {
+		t.Errorf("expected nginx to be deployed from repository: charts, got: %+v", hc.specs)
+	}
+}
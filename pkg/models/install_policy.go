@@ -0,0 +1,61 @@
+package models
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/fennet82/helga/internal/logger"
+	helga_errors "github.com/fennet82/helga/pkg/errors"
+)
+
+// installation of charts found in the chart sources that are not deployed in the namespace yet
+type InstallPolicy struct {
+	Enabled bool     `yaml:"enabled"`
+	Allow   []string `yaml:"allow"` // chart name patterns, every chart is allowed if empty
+	Deny    []string `yaml:"deny"`  // chart name patterns, takes precedence over allow
+}
+
+func (ip *InstallPolicy) String() string {
+	return fmt.Sprintf("enabled: %t, allow: %v, deny: %v", ip.Enabled, ip.Allow, ip.Deny)
+}
+
+func (ip *InstallPolicy) Validate() []error {
+	logger.GetLoggerInstance().Info(fmt.Sprintf("starting validation for install policy: %s", ip.String()))
+
+	var (
+		validationErrs []error
+		structName     = "InstallPolicy"
+	)
+
+	for _, pattern := range append(append([]string{}, ip.Allow...), ip.Deny...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			validationErrs = append(validationErrs, helga_errors.ErrValidation{StructName: structName, DerivedFromErr: fmt.Errorf("chart pattern: %s, is malformed", pattern)})
+		}
+	}
+
+	helga_errors.HandleErrors(validationErrs)
+
+	return validationErrs
+}
+
+func matchesAnyPattern(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (ip *InstallPolicy) allowsChart(chartName string) bool {
+	if ip == nil || !ip.Enabled {
+		return false
+	}
+
+	if matchesAnyPattern(ip.Deny, chartName) {
+		return false
+	}
+
+	return len(ip.Allow) == 0 || matchesAnyPattern(ip.Allow, chartName)
+}
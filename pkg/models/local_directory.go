@@ -0,0 +1,160 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/fennet82/helga/internal/logger"
+	helga_errors "github.com/fennet82/helga/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart/loader"
+)
+
+// directory packaged charts are dropped into, used by disconnected sites
+type LocalDirectory struct {
+	Path            string `yaml:"path"`
+	DecideByVersion bool   `yaml:"decideByVersion"` // will decide by date if not true
+	Recursive       bool   `yaml:"recursive"`
+
+	// archives are only re-read when their modification time changes
+	mu    sync.Mutex
+	cache map[string]LocalHelmPackage
+}
+
+func (l *LocalDirectory) String() string {
+	return l.Path
+}
+
+func (l *LocalDirectory) Validate() []error {
+	logger.GetLoggerInstance().Info(fmt.Sprintf("starting validation for local directory: %s", l.String()))
+
+	var (
+		validationErrs []error
+		structName     = "LocalDirectory"
+	)
+
+	if l.Path == "" {
+		validationErrs = append(validationErrs, helga_errors.ErrValidation{StructName: structName, DerivedFromErr: errors.New("path field cannot be empty")})
+	} else if !filepath.IsAbs(l.Path) {
+		validationErrs = append(validationErrs, helga_errors.ErrValidation{StructName: structName, DerivedFromErr: fmt.Errorf("path: %s, needs to be absolute", l.Path)})
+	}
+
+	helga_errors.HandleErrors(validationErrs)
+
+	return validationErrs
+}
+
+func (l *LocalDirectory) listArchives() ([]string, error) {
+	var archives []string
+
+	err := filepath.WalkDir(l.Path, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if p != l.Path && !l.Recursive {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if strings.HasSuffix(d.Name(), ".tgz") {
+			archives = append(archives, p)
+		}
+
+		return nil
+	})
+
+	return archives, err
+}
+
+func (l *LocalDirectory) loadArchive(archivePath string) (LocalHelmPackage, error) {
+	info, err := os.Stat(archivePath)
+	if err != nil {
+		return LocalHelmPackage{}, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.cache == nil {
+		l.cache = make(map[string]LocalHelmPackage)
+	}
+
+	if cached, exists := l.cache[archivePath]; exists && cached.TimeModified.Equal(info.ModTime()) {
+		return cached, nil
+	}
+
+	ch, err := loader.LoadFile(archivePath)
+	if err != nil {
+		return LocalHelmPackage{}, err
+	}
+
+	pkg := LocalHelmPackage{FilePath: archivePath, Metadata: ch.Metadata, TimeModified: info.ModTime()}
+	l.cache[archivePath] = pkg
+
+	return pkg, nil
+}
+
+func (l *LocalDirectory) GetLatestCharts(_ context.Context, accept ChartFilter) (map[string]HelmChart, error) {
+	logger.GetLoggerInstance().Info(fmt.Sprintf("scanning local directory: %s for helm pkgs", l.String()))
+
+	archives, err := l.listArchives()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't scan local directory: %s, err: %w", l.String(), err)
+	}
+
+	localHelmPackages := make(map[string]HelmChart)
+
+	for _, a := range archives {
+		pkg, err := l.loadArchive(a)
+		if err != nil {
+			helga_errors.HandleError(fmt.Errorf("couldn't load chart archive: %s, skipping it, err: %w", a, err))
+			continue
+		}
+
+		if err := pkg.Validate(); err != nil {
+			helga_errors.HandleError(fmt.Errorf("validation failed for pkg found in local directory reason: %s", err.Error()))
+			continue
+		}
+
+		if !accept(pkg, nil) {
+			continue
+		}
+
+		seenHelmPkg, exists := localHelmPackages[pkg.Name()]
+		if exists {
+			newer, err := DetermineNewerPkg(seenHelmPkg, pkg, l.DecideByVersion)
+			if err != nil {
+				helga_errors.HandleError(err)
+				continue
+			}
+
+			localHelmPackages[pkg.Name()] = newer
+		} else {
+			localHelmPackages[pkg.Name()] = pkg
+		}
+	}
+
+	return localHelmPackages, nil
+}
+
+// charts are installed straight from the archive on disk
+func (l *LocalDirectory) ResolveChartRef(chart HelmChart) (string, error) {
+	lhp, ok := chart.(LocalHelmPackage)
+	if !ok {
+		return "", fmt.Errorf("chart: %s was not found in local directory: %s", chart.Name(), l.String())
+	}
+
+	return lhp.FilePath, nil
+}
+
+func (l *LocalDirectory) ComparesByVersion(_ HelmChart) bool {
+	return l.DecideByVersion
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+func orNone(version string) string {
+	if version == "" {
+		return "none"
+	}
+
+	return version
+}
+
+func splitManifestLines(manifest string) []string {
+	if manifest == "" {
+		return nil
+	}
+
+	if !strings.HasSuffix(manifest, "\n") {
+		manifest += "\n"
+	}
+
+	lines := strings.SplitAfter(manifest, "\n")
+
+	return lines[:len(lines)-1]
+}
+
+// unified diff between the deployed and the rendered manifest of a release, installs are diffed against an empty manifest
+func manifestDiff(release, fromVersion, toVersion, deployed, rendered string) string {
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitManifestLines(deployed),
+		B:        splitManifestLines(rendered),
+		FromFile: fmt.Sprintf("%s (%s)", release, orNone(fromVersion)),
+		ToFile:   fmt.Sprintf("%s (%s)", release, toVersion),
+		Context:  3,
+	})
+	if err != nil {
+		return fmt.Sprintf("unavailable: %s", err.Error())
+	}
+
+	if diff == "" {
+		return "no manifest changes"
+	}
+
+	return diff
+}
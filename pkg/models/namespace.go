@@ -3,20 +3,44 @@ package models
 import (
 	"context"
 	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/fennet82/helga/internal/logger"
+	"github.com/fennet82/helga/internal/metrics"
 	"github.com/fennet82/helga/internal/vars"
 	helga_errors "github.com/fennet82/helga/pkg/errors"
 	helmclient "github.com/mittwald/go-helm-client"
+	"helm.sh/helm/v3/pkg/release"
+	"sigs.k8s.io/yaml"
 	"slices"
 )
 
 type Namespace struct {
-	Name         string    `yaml:"name"`
-	SyncInterval uint16    `yaml:"sync_interval"`
-	Artifact     *Artifact `yaml:"artifact"`
-	helmClient   helmclient.Client
+	Name             string            `yaml:"name"`
+	SyncInterval     uint16            `yaml:"sync_interval"`
+	Artifact         *Artifact         `yaml:"artifact"`
+	OCIRegistries    []*OCIRegistry    `yaml:"oci_registries"`
+	HelmRepositories []*HelmRepository `yaml:"helm_repositories"`
+	LocalDirectories []*LocalDirectory `yaml:"local_directories"`
+	Charts           []*ChartConfig    `yaml:"charts"`
+	VersionPolicy    *VersionPolicy    `yaml:"version_policy"`
+	Prune            *PrunePolicy      `yaml:"prune"`
+	InstallNew       *InstallPolicy    `yaml:"install_new"`
+	AdoptReleases    []string          `yaml:"adopt_releases"` // release name patterns helga takes over although it did not install them
+	// values files fetched from next to the chart in its source, e.g. "values-prod.yaml" or "{chart}-values.yaml"
+	CompanionValuesFiles []string `yaml:"companion_values_files"`
+	ValuesOverlay        `yaml:",inline"`
+	helmClient           helmclient.Client
+	// name of the cluster the namespace is synced in, set when its helm client is initiated
+	clusterName string
+	// unix nanos of the last completed sync cycle, read by the liveness check
+	lastCycleCompleted atomic.Int64
+	// values layers of the global config and the cluster the namespace belongs to
+	inheritedValues []*ValuesOverlay
 }
 
 func (ns *Namespace) String() string {
@@ -39,8 +63,48 @@ func (ns *Namespace) Validate() []error {
 		validationErrs = append(validationErrs, helga_errors.ErrValidation{StructName: structName, DerivedFromErr: fmt.Errorf("sync interval for namespace: %s, needs to be above: %d currently: %d", ns.Name, vars.SYNC_INTERVAL_DEFAULT_RETENTION, ns.SyncInterval)})
 	}
 
-	if errs := ns.Artifact.Validate(); len(errs) > 0 {
-		validationErrs = append(validationErrs, helga_errors.ErrValidation{StructName: structName, DerivedFromErr: fmt.Errorf("error artifact with domain: %s, did not pass validation", ns.Artifact.Domain)})
+	sources := ns.ChartSources()
+	if len(sources) == 0 {
+		validationErrs = append(validationErrs, helga_errors.ErrValidation{StructName: structName, DerivedFromErr: fmt.Errorf("namespace: %s, has no chart source configured", ns.Name)})
+	}
+
+	for _, src := range sources {
+		if errs := src.Validate(); len(errs) > 0 {
+			validationErrs = append(validationErrs, helga_errors.ErrValidation{StructName: structName, DerivedFromErr: fmt.Errorf("error chart source: %s, did not pass validation", src.String())})
+		}
+	}
+
+	if ns.VersionPolicy != nil && len(ns.VersionPolicy.Validate()) > 0 {
+		validationErrs = append(validationErrs, helga_errors.ErrValidation{StructName: structName, DerivedFromErr: fmt.Errorf("version policy of namespace: %s is invalid", ns.Name)})
+	}
+
+	if ns.Prune != nil && len(ns.Prune.Validate()) > 0 {
+		validationErrs = append(validationErrs, helga_errors.ErrValidation{StructName: structName, DerivedFromErr: fmt.Errorf("prune policy of namespace: %s is invalid", ns.Name)})
+	}
+
+	if ns.InstallNew != nil && len(ns.InstallNew.Validate()) > 0 {
+		validationErrs = append(validationErrs, helga_errors.ErrValidation{StructName: structName, DerivedFromErr: fmt.Errorf("install policy of namespace: %s is invalid", ns.Name)})
+	}
+
+	if ns.ociPlainHTTP() {
+		for _, o := range ns.OCIRegistries {
+			if !o.PlainHTTP {
+				validationErrs = append(validationErrs, helga_errors.ErrValidation{StructName: structName, DerivedFromErr: fmt.Errorf("oci registry: %s, needs plain_http since other oci registries of namespace: %s use it", o.String(), ns.Name)})
+			}
+		}
+	}
+
+	for _, pattern := range ns.AdoptReleases {
+		if _, err := path.Match(pattern, ""); err != nil {
+			validationErrs = append(validationErrs, helga_errors.ErrValidation{StructName: structName, DerivedFromErr: fmt.Errorf("adopt release pattern: %s, is malformed", pattern)})
+		}
+	}
+
+	// a chart config is never ignored, a typo in its version constraint would upgrade the chart to its latest version
+	for _, cc := range ns.Charts {
+		if errs := cc.Validate(); len(errs) > 0 {
+			validationErrs = append(validationErrs, helga_errors.ErrValidation{StructName: structName, DerivedFromErr: fmt.Errorf("chart config: %s of namespace: %s is invalid", cc.String(), ns.Name)})
+		}
 	}
 
 	helga_errors.HandleErrors(validationErrs)
@@ -48,7 +112,103 @@ func (ns *Namespace) Validate() []error {
 	return validationErrs
 }
 
+func (ns *Namespace) InheritValues(layers ...*ValuesOverlay) {
+	ns.inheritedValues = layers
+}
+
+// values of a chart merged in the order global -> cluster -> namespace -> companion values files -> chart
+func (ns *Namespace) effectiveValues(ctx context.Context, pkg SourcedChart) (map[string]any, error) {
+	layers := append(append([]*ValuesOverlay{}, ns.inheritedValues...), &ns.ValuesOverlay)
+
+	companionValues, err := fetchCompanionValues(ctx, pkg, ns.CompanionValuesFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	layers = append(layers, companionValues)
+
+	if cc := ns.GetChartConfig(pkg.Name()); cc != nil {
+		layers = append(layers, &cc.ValuesOverlay)
+	}
+
+	return mergeValuesOverlays(layers...)
+}
+
+func (ns *Namespace) newChartDeployment(ctx context.Context, releaseName string, pkg SourcedChart) (ChartDeployment, error) {
+	values, err := ns.effectiveValues(ctx, pkg)
+	if err != nil {
+		return ChartDeployment{}, fmt.Errorf("error building values of chart: %s for namespace: %s, err: %w", pkg.Name(), ns.String(), err)
+	}
+
+	valuesYaml, err := yaml.Marshal(values)
+	if err != nil {
+		return ChartDeployment{}, fmt.Errorf("error marshaling values of chart: %s for namespace: %s, err: %w", pkg.Name(), ns.String(), err)
+	}
+
+	return ChartDeployment{SourcedChart: pkg, ReleaseName: releaseName, ValuesYaml: string(valuesYaml), ValuesChecksum: valuesChecksum(valuesYaml)}, nil
+}
+
+func (ns *Namespace) GetChartConfig(chartName string) *ChartConfig {
+	for _, cc := range ns.Charts {
+		if cc.Name == chartName {
+			return cc
+		}
+	}
+
+	return nil
+}
+
+// rejects charts not allowed by the version policy of their repo (or the namespace when
+// the repo has none) and charts outside of the version constraint configured for them
+func (ns *Namespace) acceptChart(chart HelmChart, policy *VersionPolicy) bool {
+	if policy == nil {
+		policy = ns.VersionPolicy
+	}
+
+	if policy != nil {
+		if allowed, reason := policy.Allows(chart); !allowed {
+			logger.GetLoggerInstance().Info(fmt.Sprintf("chart: %s skipped by version policy of namespace: %s, reason: %s", chart.Name(), ns.String(), reason))
+			return false
+		}
+	}
+
+	cc := ns.GetChartConfig(chart.Name())
+	if cc == nil || cc.AllowsVersion(chart.Version()) {
+		return true
+	}
+
+	logger.GetLoggerInstance().Debug(fmt.Sprintf("chart: %s version: %s does not satisfy constraint: %s of namespace: %s, skipping it", chart.Name(), chart.Version(), cc.Version, ns.String()))
+
+	return false
+}
+
+func (ns *Namespace) ChartSources() []ChartSource {
+	sources := []ChartSource{}
+
+	if ns.Artifact != nil {
+		sources = append(sources, ns.Artifact)
+	}
+
+	for _, o := range ns.OCIRegistries {
+		sources = append(sources, o)
+	}
+
+	for _, h := range ns.HelmRepositories {
+		sources = append(sources, h)
+	}
+
+	for _, l := range ns.LocalDirectories {
+		sources = append(sources, l)
+	}
+
+	return sources
+}
+
 func (ns *Namespace) addOrUpdateHelmRepos() {
+	if ns.Artifact == nil {
+		return
+	}
+
 	entries := ns.Artifact.GetArtifactReposAsEntries()
 
 	for i, e := range entries {
@@ -63,13 +223,92 @@ func (ns *Namespace) addOrUpdateHelmRepos() {
 	}
 }
 
-func (ns *Namespace) getDeployedReleases() ([]HelmChart, error) {
+// the index cached by helm is only downloaded when a repository is added, so charts published since
+// are refreshed before deploying from it. failures are only logged, the deployment reports the chart missing
+func (ns *Namespace) refreshHelmRepositories(deployments []ChartDeployment) {
+	refreshed := make(map[*HelmRepository]struct{})
+
+	for _, pkg := range deployments {
+		h, ok := pkg.Source.(*HelmRepository)
+		if !ok {
+			continue
+		}
+
+		if _, done := refreshed[h]; done {
+			continue
+		}
+
+		refreshed[h] = struct{}{}
+
+		if err := ns.helmClient.AddOrUpdateChartRepo(*h.GetAsHelmRepoEntry()); err != nil {
+			helga_errors.HandleError(fmt.Errorf("error occured while refreshing index of helm repository: %s, derived from err: %w", h.String(), err))
+		}
+	}
+}
+
+func (ns *Namespace) addOrUpdateHelmRepositories() {
+	for i := len(ns.HelmRepositories) - 1; i >= 0; i-- {
+		h := ns.HelmRepositories[i]
+
+		if err := ns.helmClient.AddOrUpdateChartRepo(*h.GetAsHelmRepoEntry()); err != nil {
+			helga_errors.HandleError(fmt.Errorf(
+				"error occured while trying to insert helm repository: %s, removing from chart sources, derived from err: %w", h.String(), err,
+			))
+
+			ns.HelmRepositories = slices.Delete(ns.HelmRepositories, i, i+1)
+		}
+	}
+}
+
+func (ns *Namespace) loginOCIRegistries() {
+	for i := len(ns.OCIRegistries) - 1; i >= 0; i-- {
+		o := ns.OCIRegistries[i]
+
+		if err := o.login(ns.helmClient.GetSettings().RegistryConfig); err != nil {
+			helga_errors.HandleError(fmt.Errorf(
+				"error occured while trying to login to oci registry: %s, removing from chart sources, derived from err: %w", o.String(), err,
+			))
+
+			ns.OCIRegistries = slices.Delete(ns.OCIRegistries, i, i+1)
+		}
+	}
+}
+
+// charts of every oci registry are pulled through the same registry client of the helm client
+func (ns *Namespace) ociPlainHTTP() bool {
+	for _, o := range ns.OCIRegistries {
+		if o.PlainHTTP {
+			return true
+		}
+	}
+
+	return false
+}
+
+// the registry client go-helm-client creates only pulls over https
+func (ns *Namespace) configureOCIPulls() error {
+	hc, ok := ns.helmClient.(*helmclient.HelmClient)
+	if !ok || !ns.ociPlainHTTP() {
+		return nil
+	}
+
+	client, err := newOCIPullClient(hc.Settings.RegistryConfig, true)
+	if err != nil {
+		return fmt.Errorf("error creating plain http registry client for namespace: %s, err: %w", ns.String(), err)
+	}
+
+	hc.ActionConfig.RegistryClient = client
+
+	return nil
+}
+
+func (ns *Namespace) getDeployedReleases() ([]HelmReleaseInfo, error) {
 	releases, err := ns.helmClient.ListDeployedReleases()
 	if err != nil {
 		return nil, err
 	}
 
-	HelmReleaseInfoList := make([]HelmChart, len(releases))
+	HelmReleaseInfoList := make([]HelmReleaseInfo, len(releases))
 	for i, rel := range releases {
 		HelmReleaseInfoList[i] = HelmReleaseInfo{Release: *rel}
 	}
@@ -77,12 +316,57 @@ func (ns *Namespace) getDeployedReleases() ([]HelmChart, error) {
 	return HelmReleaseInfoList, nil
 }
 
-func (ns *Namespace) syncHelmPackages() (releasesToDelete []HelmChart, chartsToDeploy []HelmChart, err error) {
-	releasesToDelete = nil
-	chartsToDeploy = nil
-	err = nil
+// helga only manages releases stamped with its ownership label or explicitly adopted
+func (ns *Namespace) ownsRelease(rel HelmReleaseInfo) bool {
+	if rel.Labels[vars.OWNERSHIP_LABEL_KEY] == vars.OWNERSHIP_LABEL_VALUE {
+		return true
+	}
+
+	return matchesAnyPattern(ns.AdoptReleases, rel.Release.Name)
+}
+
+func ownershipLabels() map[string]string {
+	return map[string]string{vars.OWNERSHIP_LABEL_KEY: vars.OWNERSHIP_LABEL_VALUE}
+}
+
+// chart picked for deployment together with the values it is deployed with
+type ChartDeployment struct {
+	SourcedChart
+	// release the chart is deployed as, the release being upgraded or the chart name for installs
+	ReleaseName    string
+	ValuesYaml     string
+	ValuesChecksum string
+	// manifest of the release the deployment upgrades, empty for installs
+	DeployedManifest string
+	DeployedVersion  string
+}
+
+// releases without a checksum label only count as changed when there are values to apply
+func (cd ChartDeployment) valuesChangedFrom(rel HelmReleaseInfo) bool {
+	deployedChecksum, stamped := rel.Labels[vars.VALUES_CHECKSUM_LABEL_KEY]
+	if !stamped {
+		return strings.TrimSpace(cd.ValuesYaml) != "{}"
+	}
+
+	return deployedChecksum != cd.ValuesChecksum
+}
+
+func (cd ChartDeployment) labels() map[string]string {
+	labels := ownershipLabels()
+	labels[vars.VALUES_CHECKSUM_LABEL_KEY] = cd.ValuesChecksum
 
-	logger.GetLoggerInstance().Info(fmt.Sprintf("starting sync between releases and artifactory charts for namespace: %s", ns.String()))
+	return labels
+}
+
+// outcome of comparing the deployed releases of a namespace with its chart sources
+type syncPlan struct {
+	releasesToDelete []HelmReleaseInfo
+	chartsToDeploy   []ChartDeployment
+	entries          []PlanEntry
+}
+
+func (ns *Namespace) syncHelmPackages(ctx context.Context) (plan syncPlan, err error) {
+	logger.GetLoggerInstance().Info(fmt.Sprintf("starting sync between releases and chart sources for namespace: %s", ns.String()))
 
 	deployedReleases, err := ns.getDeployedReleases()
 	if err != nil {
@@ -90,70 +374,469 @@ func (ns *Namespace) syncHelmPackages() (releasesToDelete []HelmChart, chartsToD
 		return
 	}
 
-	artifactoryPkgsMap := ns.Artifact.GetChartPkgsInArtifact()
-	if len(artifactoryPkgsMap) == 0 {
-		err = fmt.Errorf("pkgs map recieved from artifactory for namespace: %s, is empty", ns.String())
+	// every chart found in the sources, also the ones without a version allowed by the namespace
+	presentCharts := make(map[string]struct{})
+
+	sourcePkgsMap, errs := GetLatestChartsFromSources(ctx, ns.ChartSources(), func(chart HelmChart, policy *VersionPolicy) bool {
+		presentCharts[chart.Name()] = struct{}{}
+		return ns.acceptChart(chart, policy)
+	})
+	helga_errors.HandleErrors(errs)
+
+	if len(presentCharts) == 0 {
+		err = fmt.Errorf("pkgs map recieved from chart sources for namespace: %s, is empty", ns.String())
 		return
 	}
 
+	// a chart missing because its source failed must never be mistaken for a removed chart
+	sourcesComplete := len(errs) == 0
+
+	deployedCharts := make(map[string]struct{})
+
 	for _, rel := range deployedReleases {
-		artifactoryHelmPkg, exists := artifactoryPkgsMap[rel.Name()]
-		if exists {
-			decideByVersion := ns.Artifact.GetRepoByName(artifactoryHelmPkg.(ArtifactHelmPackage).Repo).DecideByVersion
-
-			pkg, err := DetermineNewerPkg(rel, artifactoryHelmPkg, decideByVersion)
-			if err != nil {
-				helga_errors.HandleError(fmt.Errorf("error occured while syncing pkgs for namespace: %s, err: %w", ns.Name, err))
-				continue
-			}
+		deployedCharts[rel.Name()] = struct{}{}
+		deployedCharts[rel.Release.Name] = struct{}{}
+
+		entry := PlanEntry{Release: rel.Release.Name, Chart: rel.Name(), CurrentVersion: rel.Version(), Action: PlanActionNone}
 
-			pkgPtr, ok1 := pkg.(*ArtifactHelmPackage)
-			ahpPtr, ok2 := artifactoryHelmPkg.(*ArtifactHelmPackage)
+		if !ns.ownsRelease(rel) {
+			logger.GetLoggerInstance().Debug(fmt.Sprintf("release: %s in namespace: %s is not owned by helga, ignoring it", rel.Release.Name, ns.String()))
 
-			if ok1 && ok2 && pkgPtr == ahpPtr {
-				chartsToDeploy = append(chartsToDeploy, artifactoryHelmPkg)
+			entry.Reason = PlanReasonUnmanaged
+			plan.entries = append(plan.entries, entry)
+
+			continue
+		}
+
+		sourcePkg, exists := sourcePkgsMap[rel.Name()]
+		if _, present := presentCharts[rel.Name()]; !exists && present {
+			logger.GetLoggerInstance().Info(fmt.Sprintf("namespace: %s, chart: %s of release: %s has no version allowed by the namespace, keeping the release", ns.String(), rel.Name(), rel.Release.Name))
+
+			entry.Reason = PlanReasonNoAllowedVersion
+			plan.entries = append(plan.entries, entry)
+
+			continue
+		}
+
+		if !exists {
+			entry.Reason = PlanReasonNotInSources
+
+			if sourcesComplete {
+				plan.releasesToDelete = append(plan.releasesToDelete, rel)
+			} else {
+				entry.Reason = PlanReasonSourcesIncomplete
 			}
-		} else {
-			releasesToDelete = append(releasesToDelete, rel)
+
+			plan.entries = append(plan.entries, entry)
+
+			continue
 		}
+
+		entry.CandidateVersion = sourcePkg.Version()
+		entry.Source = sourcePkg.Source.String()
+
+		pkg, reason, err := DetermineNewerPkgWithReason(rel, sourcePkg, sourcePkg.ComparesByVersion())
+		if err != nil {
+			helga_errors.HandleError(fmt.Errorf("error occured while syncing pkgs for namespace: %s, err: %w", ns.Name, err))
+
+			entry.Reason = err.Error()
+			plan.entries = append(plan.entries, entry)
+
+			continue
+		}
+
+		entry.Reason = reason
+
+		logger.GetLoggerInstance().Info(fmt.Sprintf(
+			"namespace: %s, chart: %s, deployed version: %s, candidate version: %s from: %s, picked version: %s, reason: %s",
+			ns.String(), rel.Name(), rel.Version(), sourcePkg.Version(), sourcePkg.Source.String(), pkg.Version(), reason,
+		))
+
+		_, keepRelease := pkg.(HelmReleaseInfo)
+
+		// a kept release is only redeployed for changed values when the source still serves its version
+		if keepRelease && sourcePkg.Version() != rel.Version() {
+			plan.entries = append(plan.entries, entry)
+			continue
+		}
+
+		deployment, err := ns.newChartDeployment(ctx, rel.Release.Name, sourcePkg)
+		if err != nil {
+			helga_errors.HandleError(err)
+
+			entry.Reason = err.Error()
+			plan.entries = append(plan.entries, entry)
+
+			continue
+		}
+
+		deployment.DeployedManifest = rel.Manifest
+		deployment.DeployedVersion = rel.Version()
+
+		if !keepRelease {
+			entry.Action = PlanActionUpgrade
+			plan.chartsToDeploy = append(plan.chartsToDeploy, deployment)
+		} else if deployment.valuesChangedFrom(rel) {
+			logger.GetLoggerInstance().Info(fmt.Sprintf("namespace: %s, chart: %s values changed, upgrading release: %s", ns.String(), rel.Name(), rel.Release.Name))
+
+			entry.Action = PlanActionUpgrade
+			entry.Reason = PlanReasonValuesChanged
+			plan.chartsToDeploy = append(plan.chartsToDeploy, deployment)
+		}
+
+		plan.entries = append(plan.entries, entry)
+	}
+
+	// charts that are neither deployed as a chart nor clash with the name of an existing release
+	for name, sourcePkg := range sourcePkgsMap {
+		if _, deployed := deployedCharts[name]; deployed || !ns.InstallNew.allowsChart(name) {
+			continue
+		}
+
+		deployment, err := ns.newChartDeployment(ctx, name, sourcePkg)
+		if err != nil {
+			helga_errors.HandleError(err)
+			continue
+		}
+
+		logger.GetLoggerInstance().Info(fmt.Sprintf("namespace: %s, chart: %s version: %s from: %s is not deployed yet, installing it", ns.String(), name, sourcePkg.Version(), sourcePkg.Source.String()))
+
+		plan.chartsToDeploy = append(plan.chartsToDeploy, deployment)
+		plan.entries = append(plan.entries, PlanEntry{
+			Release:          name,
+			Chart:            name,
+			CandidateVersion: sourcePkg.Version(),
+			Source:           sourcePkg.Source.String(),
+			Reason:           PlanReasonNotDeployed,
+			Action:           PlanActionInstall,
+		})
 	}
 
 	return
 }
 
-func (ns *Namespace) SyncHelmPkgsWithCluster() {
-	// it's important to notice that for now we dont delete releases from the cluster but it can easily be implemented in the code
+func (ns *Namespace) pruneReleases(releases []HelmReleaseInfo) {
+	mode := ns.Prune.mode()
+	if mode == PruneModeOff || len(releases) == 0 {
+		return
+	}
+
+	toPrune, err := ns.Prune.selectReleasesToPrune(releases)
+	if err != nil {
+		helga_errors.HandleError(fmt.Errorf("pruning namespace: %s, err: %w", ns.String(), err))
+		return
+	}
+
+	for _, rel := range toPrune {
+		if mode == PruneModeDryRun {
+			logger.GetLoggerInstance().Info(fmt.Sprintf("dry-run: would prune release: %s from namespace: %s", rel.Release.Name, ns.String()))
+			continue
+		}
+
+		logger.GetLoggerInstance().Info(fmt.Sprintf("pruning release: %s from namespace: %s", rel.Release.Name, ns.String()))
+
+		if err := ns.helmClient.UninstallReleaseByName(rel.Release.Name); err != nil {
+			helga_errors.HandleError(fmt.Errorf("error pruning release: %s from namespace: %s, err: %w", rel.Release.Name, ns.String(), err))
+			continue
+		}
+
+		metrics.ForgetChart(ns.clusterName, ns.Name, rel.Name())
+	}
+}
+
+// context for helm operations that is cancelled only once the grace period after ctx was cancelled
+// passed, so in-flight installs and upgrades can finish when helga stops
+func withGracePeriod(ctx context.Context, grace time.Duration) (context.Context, context.CancelFunc) {
+	opCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+
+	stop := context.AfterFunc(ctx, func() {
+		time.AfterFunc(grace, cancel)
+	})
+
+	return opCtx, func() {
+		stop()
+		cancel()
+	}
+}
+
+func (ns *Namespace) chartSpec(pkg ChartDeployment) (helmclient.ChartSpec, error) {
+	chartRef, err := pkg.ResolveChartRef()
+	if err != nil {
+		return helmclient.ChartSpec{}, fmt.Errorf("error resolving chart: %s from source: %s, err: %w", pkg.Name(), pkg.Source.String(), err)
+	}
+
+	return helmclient.ChartSpec{
+		ReleaseName: pkg.ReleaseName,
+		ChartName:   chartRef,
+		ValuesYaml:  pkg.ValuesYaml,
+		Version:     pkg.Version(),
+		Namespace:   ns.Name,
+		UpgradeCRDs: true,
+		Wait:        true,
+		Timeout:     30 * time.Second,
+		Labels:      pkg.labels(),
+	}, nil
+}
+
+// renders the deployment with a dry-run install or upgrade and diffs it against the deployed manifest
+func (ns *Namespace) manifestDiff(ctx context.Context, pkg ChartDeployment) (string, error) {
+	chartSpec, err := ns.chartSpec(pkg)
+	if err != nil {
+		return "", err
+	}
+
+	// a dry-run must not touch the crds of the cluster
+	chartSpec.DryRun = true
+	chartSpec.UpgradeCRDs = false
+	chartSpec.Wait = false
+
+	rendered, err := ns.helmClient.InstallOrUpgradeChart(ctx, &chartSpec, nil)
+	if err != nil {
+		return "", fmt.Errorf("error rendering chart: %s version: %s for namespace: %s, err: %w", pkg.Name(), pkg.Version(), ns.String(), err)
+	}
+
+	return manifestDiff(pkg.ReleaseName, pkg.DeployedVersion, pkg.Version(), pkg.DeployedManifest, rendered.Manifest), nil
+}
+
+// every deployment is logged with the manifest changes it made, diffed against the manifest the release returned
+// by helm so deployments are not rendered twice
+func (ns *Namespace) auditDeployment(pkg ChartDeployment, deployed *release.Release, deployErr error) {
+	diff := "unavailable, the deployment failed"
+	if deployErr == nil && deployed != nil {
+		diff = manifestDiff(pkg.ReleaseName, pkg.DeployedVersion, pkg.Version(), pkg.DeployedManifest, deployed.Manifest)
+	}
+
+	logger.GetLoggerInstance().Info(fmt.Sprintf(
+		"audit: namespace: %s, deployed release: %s, chart: %s, version: %s -> %s from: %s, values checksum: %s, manifest diff:\n%s",
+		ns.String(), pkg.ReleaseName, pkg.Name(), orNone(pkg.DeployedVersion), pkg.Version(), pkg.Source.String(), pkg.ValuesChecksum, diff,
+	))
+}
+
+// one sync pass over the namespace, deployment failures are logged one by one and reported together
+func (ns *Namespace) syncCycle(ctx, opCtx context.Context) (err error) {
+	started := time.Now()
+
+	defer func() {
+		// a panicking cycle is recorded as failed before the panic is passed on
+		if r := recover(); r != nil {
+			metrics.ObserveSyncCycle(ns.clusterName, ns.Name, fmt.Errorf("panic occured: %v", r), started)
+			panic(r)
+		}
+
+		metrics.ObserveSyncCycle(ns.clusterName, ns.Name, err, started)
+	}()
+
+	plan, err := ns.syncHelmPackages(ctx)
+	if err != nil {
+		return fmt.Errorf("couldnt sync pkgs on namespace: %s, because error occured in the sync pkgs. err: %w", ns.String(), err)
+	}
+
+	for _, entry := range plan.entries {
+		if entry.CurrentVersion != "" && entry.Reason != PlanReasonUnmanaged {
+			metrics.SetChartVersions(ns.clusterName, ns.Name, entry.Chart, entry.CurrentVersion, entry.CandidateVersion)
+		}
+	}
+
+	ns.pruneReleases(plan.releasesToDelete)
+	ns.refreshHelmRepositories(plan.chartsToDeploy)
+
+	failed := 0
+
+	for _, pkg := range plan.chartsToDeploy {
+		if ctx.Err() != nil {
+			logger.GetLoggerInstance().Info(fmt.Sprintf("stopping namespace: %s, skipping remaining deployments", ns.String()))
+			break
+		}
+
+		chartSpec, err := ns.chartSpec(pkg)
+		if err != nil {
+			helga_errors.HandleError(err)
+			failed++
+
+			continue
+		}
+
+		action := PlanActionUpgrade
+		if pkg.DeployedVersion == "" {
+			action = PlanActionInstall
+		}
+
+		deployed, err := ns.helmClient.InstallOrUpgradeChart(opCtx, &chartSpec, nil)
+		metrics.ObserveDeployment(ns.clusterName, ns.Name, pkg.Name(), action, err)
+		ns.auditDeployment(pkg, deployed, err)
+
+		if err != nil {
+			helga_errors.HandleError(fmt.Errorf("error installing/upgrading chart: %s, err: %w", chartSpec.ChartName, err))
+			failed++
+
+			continue
+		}
+
+		// the deployed chart is now at the candidate version
+		metrics.SetChartVersions(ns.clusterName, ns.Name, pkg.Name(), pkg.Version(), pkg.Version())
+	}
+
+	if failed > 0 {
+		return helga_errors.ErrInSyncProcess{ErrMsg: fmt.Sprintf("%d of %d deployments failed in namespace: %s", failed, len(plan.chartsToDeploy), ns.String())}
+	}
+
+	return nil
+}
+
+func (ns *Namespace) MarkCycleCompleted() {
+	ns.lastCycleCompleted.Store(time.Now().UnixNano())
+}
+
+func (ns *Namespace) LastCycleCompleted() time.Time {
+	return time.Unix(0, ns.lastCycleCompleted.Load())
+}
+
+// single sync pass for one-shot runs, in-flight helm operations get the shutdown grace period when ctx is cancelled
+func (ns *Namespace) SyncOnce(ctx context.Context) error {
+	opCtx, cancelOps := withGracePeriod(ctx, vars.SHUTDOWN_GRACE_PERIOD*time.Second)
+	defer cancelOps()
+
+	return ns.syncCycle(ctx, opCtx)
+}
+
+// syncs the namespace every sync interval until ctx is cancelled, queries of a running cycle are
+// cancelled right away while in-flight helm operations get the shutdown grace period to finish
+func (ns *Namespace) SyncHelmPkgsWithCluster(ctx context.Context) {
+	opCtx, cancelOps := withGracePeriod(ctx, vars.SHUTDOWN_GRACE_PERIOD*time.Second)
+	defer cancelOps()
+
 	for {
 		func() {
 			defer func() {
 				if r := recover(); r != nil {
 					fmt.Println("panic occured: ", r)
 				}
-
-				time.Sleep(time.Duration(ns.SyncInterval) * time.Second)
 			}()
 
-			_, chartsToDeploy, err := ns.syncHelmPackages()
-			if err != nil {
-				panic(fmt.Errorf("couldnt sync pkgs on namespace: %s, because error occured in the sync pkgs. err: %w", ns.String(), err))
-			}
+			// a panicking cycle did not complete so it does not count for the liveness of the namespace
+			helga_errors.HandleError(ns.syncCycle(ctx, opCtx))
+			ns.MarkCycleCompleted()
+		}()
+
+		select {
+		case <-ctx.Done():
+			logger.GetLoggerInstance().Info(fmt.Sprintf("stopped syncing namespace: %s", ns.String()))
+			return
+		case <-time.After(time.Duration(ns.SyncInterval) * time.Second):
+		}
+	}
+}
 
-			for _, pkg := range chartsToDeploy {
-				ahp := pkg.(ArtifactHelmPackage)
+// releases deployed in the namespace and whether helga manages them
+type ReleaseStatus struct {
+	Release      string    `json:"release"`
+	Chart        string    `json:"chart"`
+	Version      string    `json:"version"`
+	Status       string    `json:"status"`
+	LastDeployed time.Time `json:"last_deployed"`
+	Managed      bool      `json:"managed"`
+}
 
-				chartSpec := helmclient.ChartSpec{
-					ReleaseName: ahp.Name(),
-					ChartName:   ns.Artifact.Domain + "/" + ahp.Repo + "/" + ahp.Path + "/" + ahp.FullName,
-					Namespace:   ns.Name,
-					UpgradeCRDs: true,
-					Wait:        true,
-					Timeout:     30 * time.Second,
-				}
+func (ns *Namespace) ReleaseStatuses() ([]ReleaseStatus, error) {
+	releases, err := ns.getDeployedReleases()
+	if err != nil {
+		return nil, fmt.Errorf("error listing releases of namespace: %s, err: %w", ns.String(), err)
+	}
 
-				if _, err := ns.helmClient.InstallOrUpgradeChart(context.Background(), &chartSpec, nil); err != nil {
-					helga_errors.HandleError(fmt.Errorf("error installing/upgrading chart: %s", chartSpec.ChartName))
-				}
-			}
-		}()
+	statuses := make([]ReleaseStatus, 0, len(releases))
+	for _, rel := range releases {
+		statuses = append(statuses, ReleaseStatus{
+			Release:      rel.Release.Name,
+			Chart:        rel.Name(),
+			Version:      rel.Version(),
+			Status:       rel.Info.Status.String(),
+			LastDeployed: rel.Time(),
+			Managed:      ns.ownsRelease(rel),
+		})
+	}
+
+	return statuses, nil
+}
+
+// runs the sync decisions of the namespace without installing, upgrading or pruning anything,
+// withDiffs renders every planned deployment and attaches its manifest diff
+func (ns *Namespace) Plan(ctx context.Context, withDiffs bool) ([]PlanEntry, error) {
+	plan, err := ns.syncHelmPackages(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("couldnt plan namespace: %s, err: %w", ns.String(), err)
+	}
+
+	ns.planPruning(plan)
+
+	if withDiffs {
+		ns.refreshHelmRepositories(plan.chartsToDeploy)
+		ns.planDiffs(ctx, plan)
+	}
+
+	sort.Slice(plan.entries, func(i, j int) bool {
+		return plan.entries[i].Release < plan.entries[j].Release
+	})
+
+	return plan.entries, nil
+}
+
+func (ns *Namespace) planDiffs(ctx context.Context, plan syncPlan) {
+	deployments := make(map[string]ChartDeployment)
+	for _, pkg := range plan.chartsToDeploy {
+		deployments[pkg.ReleaseName] = pkg
+	}
+
+	for i, entry := range plan.entries {
+		pkg, planned := deployments[entry.Release]
+		if !planned || (entry.Action != PlanActionInstall && entry.Action != PlanActionUpgrade) {
+			continue
+		}
+
+		diff, err := ns.manifestDiff(ctx, pkg)
+		if err != nil {
+			helga_errors.HandleError(err)
+			diff = fmt.Sprintf("unavailable: %s", err.Error())
+		}
+
+		plan.entries[i].Diff = diff
+	}
+}
+
+// marks the releases missing from the sources with the action the prune policy would take
+func (ns *Namespace) planPruning(plan syncPlan) {
+	mode := ns.Prune.mode()
+	if mode == PruneModeOff || len(plan.releasesToDelete) == 0 {
+		return
+	}
+
+	action := PlanActionPrune
+	if mode == PruneModeDryRun {
+		action = PlanActionPruneDryRun
+	}
+
+	reason := PlanReasonNotInSources
+
+	toPrune, err := ns.Prune.selectReleasesToPrune(plan.releasesToDelete)
+	if err != nil {
+		reason = err.Error()
+	}
+
+	pruned := make(map[string]struct{})
+	for _, rel := range toPrune {
+		pruned[rel.Release.Name] = struct{}{}
+	}
+
+	for i, entry := range plan.entries {
+		if entry.Reason != PlanReasonNotInSources {
+			continue
+		}
+
+		if _, selected := pruned[entry.Release]; selected {
+			plan.entries[i].Action = action
+		} else if err != nil {
+			plan.entries[i].Reason = reason
+		} else {
+			plan.entries[i].Reason = PlanReasonProtected
+		}
 	}
 }
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	helmclient "github.com/mittwald/go-helm-client"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+func TestSyncHelmPackagesUpgradesReleasesUnderTheirOwnName(t *testing.T) {
+	ns := &Namespace{
+		Name:             "web",
+		LocalDirectories: []*LocalDirectory{newTestLocalDirectory(t, map[string][]string{"nginx": {"1.1.0"}, "redis": {"7.0.0"}})},
+		AdoptReleases:    []string{"my-*"},
+		InstallNew:       &InstallPolicy{Enabled: true},
+		helmClient: &fakeHelmClient{releases: []*release.Release{
+			testRelease("my-nginx", "nginx", "1.0.0", nil),
+		}},
+	}
+
+	plan, err := ns.syncHelmPackages(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	releases := make(map[string]string)
+	for _, pkg := range plan.chartsToDeploy {
+		spec, err := ns.chartSpec(pkg)
+		if err != nil {
+			t.Fatalf("building chart spec of: %s: %v", pkg.ReleaseName, err)
+		}
+
+		releases[spec.ReleaseName] = pkg.Name()
+	}
+
+	want := map[string]string{"my-nginx": "nginx", "redis": "redis"}
+	if len(releases) != len(want) || releases["my-nginx"] != "nginx" || releases["redis"] != "redis" {
+		t.Fatalf("expected release -> chart deployments: %v, got: %v", want, releases)
+	}
+
+	for _, e := range plan.entries {
+		if e.Chart == "nginx" && (e.Release != "my-nginx" || e.Action != PlanActionUpgrade) {
+			t.Errorf("expected upgrade of release: my-nginx, got: %+v", e)
+		}
+	}
+}
+
+// records the chart specs it was asked to deploy and returns their release
+type deployingHelmClient struct {
+	fakeHelmClient
+	specs []helmclient.ChartSpec
+	repos []string // helm repositories added or refreshed
+}
+
+func (d *deployingHelmClient) AddOrUpdateChartRepo(entry repo.Entry) error {
+	d.repos = append(d.repos, entry.Name)
+
+	return nil
+}
+
+func (d *deployingHelmClient) InstallOrUpgradeChart(_ context.Context, spec *helmclient.ChartSpec, _ *helmclient.GenericHelmOptions) (*release.Release, error) {
+	d.specs = append(d.specs, *spec)
+
+	return &release.Release{Name: spec.ReleaseName, Manifest: "kind: Deployment\n"}, nil
+}
+
+func TestSyncCycleDeploysWithoutRenderingTwice(t *testing.T) {
+	hc := &deployingHelmClient{fakeHelmClient: fakeHelmClient{releases: []*release.Release{
+		testRelease("nginx", "nginx", "1.0.0", ownershipLabels()),
+	}}}
+
+	ns := &Namespace{
+		Name:             "web",
+		LocalDirectories: []*LocalDirectory{newTestLocalDirectory(t, map[string][]string{"nginx": {"1.1.0"}})},
+		helmClient:       hc,
+	}
+
+	if err := ns.syncCycle(context.Background(), context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(hc.specs) != 1 || hc.specs[0].DryRun || hc.specs[0].ReleaseName != "nginx" {
+		t.Fatalf("expected a single upgrade of release: nginx without a dry-run, got: %+v", hc.specs)
+	}
+}
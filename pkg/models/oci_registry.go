@@ -0,0 +1,166 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/fennet82/helga/internal/logger"
+	"github.com/fennet82/helga/internal/vars"
+	helga_errors "github.com/fennet82/helga/pkg/errors"
+	"helm.sh/helm/v3/pkg/registry"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/retry"
+)
+
+type OCIRegistry struct {
+	Host         string   `yaml:"host"`
+	Username     string   `yaml:"username,omitempty"`
+	Password     string   `yaml:"password,omitempty"`
+	PlainHTTP    bool     `yaml:"plain_http"`
+	Repositories []string `yaml:"repositories"`
+}
+
+func (o *OCIRegistry) String() string {
+	return o.Host
+}
+
+func (o *OCIRegistry) Validate() []error {
+	logger.GetLoggerInstance().Info(fmt.Sprintf("starting validation for oci registry: %s", o.String()))
+
+	var (
+		validationErrs []error
+		structName     = "OCIRegistry"
+		hReg           = regexp.MustCompile(vars.OCI_REGISTRY_HOST_REGEX)
+	)
+
+	if !hReg.MatchString(o.Host) {
+		validationErrs = append(validationErrs, helga_errors.ErrValidation{StructName: structName, DerivedFromErr: fmt.Errorf(
+			"host: %s, did not pass regex validation please refer to this regex for fixing: %s", o.Host, vars.OCI_REGISTRY_HOST_REGEX,
+		)})
+	}
+
+	if o.Username != "" && o.Password == "" {
+		validationErrs = append(validationErrs, helga_errors.ErrValidation{StructName: structName, DerivedFromErr: errors.New("password field cannot be empty when username is set")})
+	}
+
+	if len(o.Repositories) == 0 {
+		validationErrs = append(validationErrs, helga_errors.ErrValidation{StructName: structName, DerivedFromErr: errors.New("repositories list cannot be empty")})
+	}
+
+	helga_errors.HandleErrors(validationErrs)
+
+	return validationErrs
+}
+
+func (o *OCIRegistry) newRepositoryClient(repository string) (*remote.Repository, error) {
+	repo, err := remote.NewRepository(o.Host + "/" + strings.Trim(repository, "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	repo.PlainHTTP = o.PlainHTTP
+	repo.Client = &auth.Client{
+		Client:     retry.DefaultClient,
+		Cache:      auth.NewCache(),
+		Credential: auth.StaticCredential(o.Host, auth.Credential{Username: o.Username, Password: o.Password}),
+	}
+
+	return repo, nil
+}
+
+func (o *OCIRegistry) listRepositoryTags(ctx context.Context, repository string) ([]string, error) {
+	logger.GetLoggerInstance().Info(fmt.Sprintf("sending request to list tags for oci registry: %s, repository: %s", o.String(), repository))
+
+	repo, err := o.newRepositoryClient(repository)
+	if err != nil {
+		return nil, helga_errors.ErrOCIRegistryAPI{DerivedFromErr: err, Registry: o.String(), Repository: repository}
+	}
+
+	var tags []string
+	err = repo.Tags(ctx, "", func(page []string) error {
+		tags = append(tags, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, helga_errors.ErrOCIRegistryAPI{DerivedFromErr: err, Registry: o.String(), Repository: repository}
+	}
+
+	return tags, nil
+}
+
+func (o *OCIRegistry) GetLatestCharts(ctx context.Context, accept ChartFilter) (map[string]HelmChart, error) {
+	ociHelmPackages := make(map[string]HelmChart)
+
+	for _, r := range o.Repositories {
+		tags, err := o.listRepositoryTags(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tag := range tags {
+			pkg := OCIHelmPackage{Registry: o.Host, Repository: strings.Trim(r, "/"), Tag: tag}
+
+			// tags that are not chart versions (e.g. latest, sha digests) are skipped silently
+			if err := pkg.Validate(); err != nil || !accept(pkg, nil) {
+				continue
+			}
+
+			seenHelmPkg, exists := ociHelmPackages[pkg.Name()]
+			if exists {
+				newer, err := DetermineNewerPkg(seenHelmPkg, pkg, true)
+				if err != nil {
+					helga_errors.HandleError(err)
+					continue
+				}
+
+				ociHelmPackages[pkg.Name()] = newer
+			} else {
+				ociHelmPackages[pkg.Name()] = pkg
+			}
+		}
+	}
+
+	return ociHelmPackages, nil
+}
+
+func (o *OCIRegistry) ResolveChartRef(chart HelmChart) (string, error) {
+	ohp, ok := chart.(OCIHelmPackage)
+	if !ok {
+		return "", fmt.Errorf("chart: %s was not fetched from oci registry: %s", chart.Name(), o.String())
+	}
+
+	return registry.OCIScheme + "://" + ohp.Registry + "/" + ohp.Repository, nil
+}
+
+// oci tags carry no modification time so charts are always compared by version
+func (o *OCIRegistry) ComparesByVersion(_ HelmChart) bool {
+	return true
+}
+
+// stores the registry credentials where the helm client of the namespace looks for them when pulling
+func (o *OCIRegistry) login(registryConfigPath string) error {
+	if o.Username == "" {
+		return nil
+	}
+
+	client, err := registry.NewClient(registry.ClientOptCredentialsFile(registryConfigPath))
+	if err != nil {
+		return err
+	}
+
+	return client.Login(o.Host, registry.LoginOptBasicAuth(o.Username, o.Password), registry.LoginOptPlainText(o.PlainHTTP))
+}
+
+// registry client helm pulls oci charts with, it talks plain http either to every registry or to none
+func newOCIPullClient(registryConfigPath string, plainHTTP bool) (*registry.Client, error) {
+	opts := []registry.ClientOption{registry.ClientOptCredentialsFile(registryConfigPath)}
+	if plainHTTP {
+		opts = append(opts, registry.ClientOptPlainHTTP())
+	}
+
+	return registry.NewClient(opts...)
+}
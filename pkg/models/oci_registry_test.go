@@ -0,0 +1,138 @@
+package models
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/distribution/distribution/v3/registry/handlers"
+	_ "github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/registry"
+	"helm.sh/helm/v3/pkg/release"
+	helmtime "helm.sh/helm/v3/pkg/time"
+)
+
+// in-process distribution server serving plain http
+func newTestRegistry(t *testing.T) string {
+	t.Helper()
+
+	config := &configuration.Configuration{
+		Storage: configuration.Storage{"inmemory": configuration.Parameters{}},
+	}
+	config.Log.AccessLog.Disabled = true
+	config.Log.Level = "error"
+
+	server := httptest.NewServer(handlers.NewApp(context.Background(), config))
+	t.Cleanup(server.Close)
+
+	return strings.TrimPrefix(server.URL, "http://")
+}
+
+func pushTestChart(t *testing.T, client *registry.Client, host, name, version string) {
+	t.Helper()
+
+	archive, err := chartutil.Save(&chart.Chart{Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: name, Version: version}}, t.TempDir())
+	if err != nil {
+		t.Fatalf("packaging chart: %v", err)
+	}
+
+	data, err := os.ReadFile(archive)
+	if err != nil {
+		t.Fatalf("reading chart archive: %v", err)
+	}
+
+	if _, err := client.Push(data, host+"/charts/"+name+":"+strings.ReplaceAll(version, "+", "_")); err != nil {
+		t.Fatalf("pushing chart: %s version: %s: %v", name, version, err)
+	}
+}
+
+func TestOCIRegistryAgainstInProcessRegistry(t *testing.T) {
+	host := newTestRegistry(t)
+	ctx := context.Background()
+
+	client, err := newOCIPullClient(filepath.Join(t.TempDir(), "registry.json"), true)
+	if err != nil {
+		t.Fatalf("creating registry client: %v", err)
+	}
+
+	// 1.10.0 only wins when tags are compared by semver and not lexically
+	for _, v := range []string{"1.2.0", "1.10.0", "1.9.0", "2.0.0-rc.1"} {
+		pushTestChart(t, client, host, "webapp", v)
+	}
+
+	o := &OCIRegistry{Host: host, PlainHTTP: true, Repositories: []string{"charts/webapp"}}
+
+	withoutPrereleases := &VersionPolicy{Prereleases: PrereleasesExclude}
+	charts, err := o.GetLatestCharts(ctx, func(c HelmChart, _ *VersionPolicy) bool {
+		allowed, _ := withoutPrereleases.Allows(c)
+		return allowed
+	})
+	if err != nil {
+		t.Fatalf("listing charts: %v", err)
+	}
+
+	latest, found := charts["webapp"]
+	if !found {
+		t.Fatalf("webapp not listed, got: %v", charts)
+	}
+
+	if latest.Version() != "1.10.0" {
+		t.Fatalf("expected latest version 1.10.0, got: %s", latest.Version())
+	}
+
+	ref, err := o.ResolveChartRef(latest)
+	if err != nil {
+		t.Fatalf("resolving chart ref: %v", err)
+	}
+
+	if want := "oci://" + host + "/charts/webapp"; ref != want {
+		t.Fatalf("expected chart ref: %s, got: %s", want, ref)
+	}
+
+	// the deployed release carries a real time while oci tags carry none, the version has to decide
+	deployed := HelmReleaseInfo{Release: release.Release{
+		Name:  "webapp",
+		Chart: &chart.Chart{Metadata: &chart.Metadata{Name: "webapp", Version: "1.9.0"}},
+		Info:  &release.Info{LastDeployed: helmtime.Now()},
+	}}
+
+	newer, reason, err := DetermineNewerPkgWithReason(deployed, SourcedChart{HelmChart: latest, Source: o}, o.ComparesByVersion(latest))
+	if err != nil {
+		t.Fatalf("comparing release with oci chart: %v", err)
+	}
+
+	if _, upgrade := newer.(SourcedChart); !upgrade || reason != SelectionReasonVersion {
+		t.Fatalf("expected upgrade to 1.10.0 decided by version, got: %s by %s", newer.Version(), reason)
+	}
+
+	// helm resolves oci chart refs through the pull client of the namespace
+	pulled, err := client.Pull(strings.TrimPrefix(ref, "oci://")+":"+latest.Version(), registry.PullOptWithChart(true))
+	if err != nil {
+		t.Fatalf("pulling chart over plain http: %v", err)
+	}
+
+	if pulled.Chart == nil || pulled.Chart.Meta.Version != "1.10.0" {
+		t.Fatalf("expected pulled chart version 1.10.0, got: %+v", pulled.Chart)
+	}
+}
+
+func TestNamespaceRejectsMixedPlainHTTPRegistries(t *testing.T) {
+	ns := &Namespace{
+		Name:         "webapp",
+		SyncInterval: 300,
+		OCIRegistries: []*OCIRegistry{
+			{Host: "registry.local:5000", PlainHTTP: true, Repositories: []string{"charts/webapp"}},
+			{Host: "harbor.example.com", Repositories: []string{"charts/api"}},
+		},
+	}
+
+	if errs := ns.Validate(); len(errs) != 1 {
+		t.Fatalf("expected one validation error for mixed plain_http registries, got: %v", errs)
+	}
+}
@@ -0,0 +1,31 @@
+package models
+
+// decision the sync of a namespace makes for one release or chart
+type PlanEntry struct {
+	Release          string `json:"release"`
+	Chart            string `json:"chart"`
+	CurrentVersion   string `json:"current_version,omitempty"`
+	CandidateVersion string `json:"candidate_version,omitempty"`
+	Source           string `json:"source,omitempty"`
+	Reason           string `json:"reason"` // one of the selection reasons of DetermineNewerPkgWithReason or a plan reason
+	Action           string `json:"action"`
+	Diff             string `json:"diff,omitempty"` // unified diff of the rendered manifests, only set when requested
+}
+
+const (
+	PlanActionNone        = "none"
+	PlanActionInstall     = "install"
+	PlanActionUpgrade     = "upgrade"
+	PlanActionPrune       = "prune"
+	PlanActionPruneDryRun = "prune (dry-run)"
+)
+
+const (
+	PlanReasonNotDeployed       = "not deployed"
+	PlanReasonValuesChanged     = "values changed"
+	PlanReasonUnmanaged         = "not managed by helga"
+	PlanReasonNotInSources      = "not found in chart sources"
+	PlanReasonNoAllowedVersion  = "no version allowed by the version policy or constraint"
+	PlanReasonSourcesIncomplete = "not found in chart sources, some sources failed"
+	PlanReasonProtected         = "protected from pruning"
+)
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/fennet82/helga/internal/logger"
+	"github.com/fennet82/helga/internal/vars"
+	helga_errors "github.com/fennet82/helga/pkg/errors"
+)
+
+const (
+	PruneModeOff    = "off"
+	PruneModeDryRun = "dry-run"
+	PruneModeOn     = "on"
+)
+
+// deletion of releases whose chart is no longer present in any chart source of the namespace
+type PrunePolicy struct {
+	Mode            string `yaml:"mode"`             // off (default), dry-run or on
+	ProtectionLabel string `yaml:"protection_label"` // releases labeled or charts annotated with it set to "true" are never pruned
+	MaxDeletions    uint   `yaml:"max_deletions"`    // pruning is skipped for the cycle if more releases would be deleted
+}
+
+func (pp *PrunePolicy) String() string {
+	return fmt.Sprintf("mode: %s, protection label: %s, max deletions: %d", pp.mode(), pp.protectionLabel(), pp.maxDeletions())
+}
+
+func (pp *PrunePolicy) Validate() []error {
+	logger.GetLoggerInstance().Info(fmt.Sprintf("starting validation for prune policy: %s", pp.String()))
+
+	var (
+		validationErrs []error
+		structName     = "PrunePolicy"
+	)
+
+	if !slices.Contains([]string{"", PruneModeOff, PruneModeDryRun, PruneModeOn}, pp.Mode) {
+		validationErrs = append(validationErrs, helga_errors.ErrValidation{StructName: structName, DerivedFromErr: fmt.Errorf(
+			"mode: %s, needs to be one of: %s, %s, %s", pp.Mode, PruneModeOff, PruneModeDryRun, PruneModeOn,
+		)})
+	}
+
+	helga_errors.HandleErrors(validationErrs)
+
+	return validationErrs
+}
+
+func (pp *PrunePolicy) mode() string {
+	if pp == nil || pp.Mode == "" {
+		return PruneModeOff
+	}
+
+	return pp.Mode
+}
+
+func (pp *PrunePolicy) protectionLabel() string {
+	if pp == nil || pp.ProtectionLabel == "" {
+		return vars.PRUNE_DEFAULT_PROTECTION_LABEL
+	}
+
+	return pp.ProtectionLabel
+}
+
+func (pp *PrunePolicy) maxDeletions() uint {
+	if pp == nil || pp.MaxDeletions == 0 {
+		return vars.PRUNE_DEFAULT_MAX_DELETIONS
+	}
+
+	return pp.MaxDeletions
+}
+
+func (pp *PrunePolicy) isProtected(rel HelmReleaseInfo) bool {
+	label := pp.protectionLabel()
+
+	if rel.Labels[label] == "true" {
+		return true
+	}
+
+	return rel.Chart != nil && rel.Chart.Metadata != nil && rel.Chart.Metadata.Annotations[label] == "true"
+}
+
+// returns the releases that may be deleted, none if the max deletions guard is hit
+func (pp *PrunePolicy) selectReleasesToPrune(releases []HelmReleaseInfo) ([]HelmReleaseInfo, error) {
+	var toPrune []HelmReleaseInfo
+
+	for _, rel := range releases {
+		if pp.isProtected(rel) {
+			logger.GetLoggerInstance().Info(fmt.Sprintf("release: %s is protected by label: %s, not pruning it", rel.Release.Name, pp.protectionLabel()))
+			continue
+		}
+
+		toPrune = append(toPrune, rel)
+	}
+
+	if uint(len(toPrune)) > pp.maxDeletions() {
+		return nil, fmt.Errorf("%d releases would be pruned which is above max deletions: %d, skipping pruning for this cycle", len(toPrune), pp.maxDeletions())
+	}
+
+	return toPrune, nil
+}
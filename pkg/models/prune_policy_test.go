@@ -0,0 +1,286 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/fennet82/helga/internal/vars"
+	helmclient "github.com/mittwald/go-helm-client"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// helm client listing a fixed set of releases, any other call panics
+type fakeHelmClient struct {
+	helmclient.Client
+	releases []*release.Release
+}
+
+func (f *fakeHelmClient) ListDeployedReleases() ([]*release.Release, error) {
+	return f.releases, nil
+}
+
+func testRelease(name, chartName, version string, labels map[string]string) *release.Release {
+	return &release.Release{
+		Name:   name,
+		Chart:  &chart.Chart{Metadata: &chart.Metadata{Name: chartName, Version: version}},
+		Info:   &release.Info{},
+		Labels: labels,
+	}
+}
+
+func testReleaseInfo(name string, labels map[string]string) HelmReleaseInfo {
+	return HelmReleaseInfo{Release: *testRelease(name, name, "1.0.0", labels)}
+}
+
+func releaseNames(releases []HelmReleaseInfo) string {
+	names := make([]string, len(releases))
+	for i, rel := range releases {
+		names[i] = rel.Release.Name
+	}
+
+	return strings.Join(names, ",")
+}
+
+func TestPrunePolicySelectReleasesToPrune(t *testing.T) {
+	protected := map[string]string{vars.PRUNE_DEFAULT_PROTECTION_LABEL: "true"}
+
+	annotated := testReleaseInfo("annotated", nil)
+	annotated.Chart.Metadata.Annotations = map[string]string{"team.io/keep": "true"}
+
+	tests := []struct {
+		name     string
+		policy   *PrunePolicy
+		releases []HelmReleaseInfo
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "unprotected releases",
+			policy:   &PrunePolicy{Mode: PruneModeOn},
+			releases: []HelmReleaseInfo{testReleaseInfo("a", nil), testReleaseInfo("b", nil)},
+			want:     "a,b",
+		},
+		{
+			name:     "default protection label",
+			policy:   &PrunePolicy{Mode: PruneModeOn},
+			releases: []HelmReleaseInfo{testReleaseInfo("a", protected), testReleaseInfo("b", nil)},
+			want:     "b",
+		},
+		{
+			name:     "protection label set to false",
+			policy:   &PrunePolicy{Mode: PruneModeOn},
+			releases: []HelmReleaseInfo{testReleaseInfo("a", map[string]string{vars.PRUNE_DEFAULT_PROTECTION_LABEL: "false"})},
+			want:     "a",
+		},
+		{
+			name:     "custom protection label as chart annotation",
+			policy:   &PrunePolicy{Mode: PruneModeOn, ProtectionLabel: "team.io/keep"},
+			releases: []HelmReleaseInfo{annotated, testReleaseInfo("b", protected)},
+			want:     "b",
+		},
+		{
+			name:     "default max deletions",
+			policy:   &PrunePolicy{Mode: PruneModeOn},
+			releases: []HelmReleaseInfo{testReleaseInfo("a", nil), testReleaseInfo("b", nil), testReleaseInfo("c", nil), testReleaseInfo("d", nil)},
+			wantErr:  true,
+		},
+		{
+			name:     "protected releases do not count against max deletions",
+			policy:   &PrunePolicy{Mode: PruneModeOn, MaxDeletions: 1},
+			releases: []HelmReleaseInfo{testReleaseInfo("a", protected), testReleaseInfo("b", nil)},
+			want:     "b",
+		},
+		{
+			name:     "custom max deletions",
+			policy:   &PrunePolicy{Mode: PruneModeOn, MaxDeletions: 1},
+			releases: []HelmReleaseInfo{testReleaseInfo("a", nil), testReleaseInfo("b", nil)},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		toPrune, err := tt.policy.selectReleasesToPrune(tt.releases)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+
+		if got := releaseNames(toPrune); got != tt.want {
+			t.Errorf("%s: expected releases to prune: %q, got: %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestPrunePolicyMode(t *testing.T) {
+	var unset *PrunePolicy
+
+	if unset.mode() != PruneModeOff || (&PrunePolicy{}).mode() != PruneModeOff {
+		t.Error("expected pruning to be off unless configured")
+	}
+
+	if errs := (&PrunePolicy{Mode: "always"}).Validate(); len(errs) != 1 {
+		t.Errorf("expected one validation error for an unknown mode, got: %v", errs)
+	}
+}
+
+// local directory holding one archive per chart name and version
+func newTestLocalDirectory(t *testing.T, charts map[string][]string) *LocalDirectory {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	for name, versions := range charts {
+		for _, v := range versions {
+			if _, err := chartutil.Save(&chart.Chart{Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: name, Version: v}}, dir); err != nil {
+				t.Fatalf("packaging chart: %s version: %s: %v", name, v, err)
+			}
+		}
+	}
+
+	return &LocalDirectory{Path: dir, DecideByVersion: true}
+}
+
+func TestSyncHelmPackagesPruneGuards(t *testing.T) {
+	owned := ownershipLabels()
+
+	constraint := &ChartConfig{Name: "webapp", Version: "~1.4"}
+	constraint.Validate()
+
+	tests := []struct {
+		name        string
+		sources     map[string][]string
+		policy      *VersionPolicy
+		wantPrune   string
+		wantReasons map[string]string
+	}{
+		{
+			name:      "chart removed from sources",
+			sources:   map[string][]string{"webapp": {"1.4.2"}, "api": {"1.0.0"}},
+			wantPrune: "worker",
+			wantReasons: map[string]string{
+				"webapp": SelectionReasonEqualVersions,
+				"worker": PlanReasonNotInSources,
+				"manual": PlanReasonUnmanaged,
+			},
+		},
+		{
+			name:      "chart without a version inside its constraint",
+			sources:   map[string][]string{"webapp": {"2.0.0"}, "api": {"1.0.0"}, "worker": {"0.1.0"}},
+			wantPrune: "",
+			wantReasons: map[string]string{
+				"webapp": PlanReasonNoAllowedVersion,
+				"worker": SelectionReasonEqualVersions,
+			},
+		},
+		{
+			name:      "chart with only excluded prereleases",
+			sources:   map[string][]string{"webapp": {"1.4.2"}, "api": {"1.0.0"}, "worker": {"0.2.0-rc.1"}},
+			policy:    &VersionPolicy{Prereleases: PrereleasesExclude},
+			wantPrune: "",
+			wantReasons: map[string]string{
+				"worker": PlanReasonNoAllowedVersion,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns := &Namespace{
+				Name:             "webapp",
+				LocalDirectories: []*LocalDirectory{newTestLocalDirectory(t, tt.sources)},
+				Charts:           []*ChartConfig{constraint},
+				VersionPolicy:    tt.policy,
+				Prune:            &PrunePolicy{Mode: PruneModeOn},
+				helmClient: &fakeHelmClient{releases: []*release.Release{
+					testRelease("webapp", "webapp", "1.4.2", owned),
+					testRelease("api", "api", "1.0.0", owned),
+					testRelease("worker", "worker", "0.1.0", owned),
+					testRelease("manual", "manual", "1.0.0", nil),
+				}},
+			}
+
+			plan, err := ns.syncHelmPackages(context.Background())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got := releaseNames(plan.releasesToDelete); got != tt.wantPrune {
+				t.Errorf("expected releases to prune: %q, got: %q", tt.wantPrune, got)
+			}
+
+			reasons := make(map[string]string)
+			for _, e := range plan.entries {
+				reasons[e.Release] = e.Reason
+
+				if e.Reason == PlanReasonNoAllowedVersion && e.Action != PlanActionNone {
+					t.Errorf("expected no action for release: %s without an allowed version, got: %s", e.Release, e.Action)
+				}
+			}
+
+			for rel, reason := range tt.wantReasons {
+				if reasons[rel] != reason {
+					t.Errorf("expected release: %s reason: %q, got: %q", rel, reason, reasons[rel])
+				}
+			}
+		})
+	}
+}
+
+func TestSyncHelmPackagesKeepsReleasesWhenSourcesFail(t *testing.T) {
+	ns := &Namespace{
+		Name: "webapp",
+		LocalDirectories: []*LocalDirectory{
+			newTestLocalDirectory(t, map[string][]string{"webapp": {"1.0.0"}}),
+			{Path: "/nonexistent/helga/charts"},
+		},
+		Prune: &PrunePolicy{Mode: PruneModeOn},
+		helmClient: &fakeHelmClient{releases: []*release.Release{
+			testRelease("worker", "worker", "0.1.0", ownershipLabels()),
+		}},
+	}
+
+	plan, err := ns.syncHelmPackages(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(plan.releasesToDelete) != 0 {
+		t.Errorf("expected nothing to prune while a source failed, got: %s", releaseNames(plan.releasesToDelete))
+	}
+
+	if len(plan.entries) != 1 || plan.entries[0].Reason != PlanReasonSourcesIncomplete {
+		t.Errorf("expected release to be reported with reason: %q, got: %+v", PlanReasonSourcesIncomplete, plan.entries)
+	}
+}
+
+func TestPlanPruningMarksSelectedReleases(t *testing.T) {
+	releases := []HelmReleaseInfo{testReleaseInfo("a", nil), testReleaseInfo("b", map[string]string{vars.PRUNE_DEFAULT_PROTECTION_LABEL: "true"})}
+
+	for _, mode := range []string{PruneModeOn, PruneModeDryRun} {
+		plan := syncPlan{releasesToDelete: releases}
+		for _, rel := range releases {
+			plan.entries = append(plan.entries, PlanEntry{Release: rel.Release.Name, Reason: PlanReasonNotInSources, Action: PlanActionNone})
+		}
+
+		ns := &Namespace{Name: "webapp", Prune: &PrunePolicy{Mode: mode}}
+		ns.planPruning(plan)
+
+		sort.Slice(plan.entries, func(i, j int) bool { return plan.entries[i].Release < plan.entries[j].Release })
+
+		wantAction := PlanActionPrune
+		if mode == PruneModeDryRun {
+			wantAction = PlanActionPruneDryRun
+		}
+
+		got := fmt.Sprintf("%s/%s %s/%s", plan.entries[0].Action, plan.entries[0].Reason, plan.entries[1].Action, plan.entries[1].Reason)
+		want := fmt.Sprintf("%s/%s %s/%s", wantAction, PlanReasonNotInSources, PlanActionNone, PlanReasonProtected)
+
+		if got != want {
+			t.Errorf("mode: %s, expected entries: %s, got: %s", mode, want, got)
+		}
+	}
+}
@@ -3,8 +3,12 @@ package models
 import (
 	"errors"
 	"fmt"
+	"path"
+	"slices"
+	"strings"
 
 	"github.com/fennet82/helga/internal/logger"
+	"github.com/fennet82/helga/internal/utils"
 	helga_errors "github.com/fennet82/helga/pkg/errors"
 	"helm.sh/helm/v3/pkg/repo"
 )
@@ -12,7 +16,14 @@ import (
 type Repo struct {
 	Name            string   `yaml:"name"`
 	DecideByVersion bool     `yaml:"decideByVersion"` // will decide by date if not true
-	Paths           []string `yaml:"paths"`
+	Paths           []string `yaml:"paths"`           // exact paths or globs, "*" matches one folder and "**" any depth
+	ExcludePaths    []string `yaml:"exclude_paths"`
+
+	// artifactory item properties, e.g. env.approved: prod
+	RequiredProperties  map[string]string `yaml:"required_properties"`
+	ForbiddenProperties map[string]string `yaml:"forbidden_properties"`
+
+	VersionPolicy *VersionPolicy `yaml:"version_policy"`
 }
 
 func (r *Repo) String() string {
@@ -35,6 +46,16 @@ func (r *Repo) Validate() []error {
 		validationErrs = append(validationErrs, helga_errors.ErrValidation{StructName: structName, DerivedFromErr: errors.New("length of repo paths list cannot be empty")})
 	}
 
+	for _, p := range append(slices.Clone(r.Paths), r.ExcludePaths...) {
+		if _, err := path.Match(p, ""); err != nil {
+			validationErrs = append(validationErrs, helga_errors.ErrValidation{StructName: structName, DerivedFromErr: fmt.Errorf("path pattern: %s, is malformed", p)})
+		}
+	}
+
+	if r.VersionPolicy != nil && len(r.VersionPolicy.Validate()) > 0 {
+		validationErrs = append(validationErrs, helga_errors.ErrValidation{StructName: structName, DerivedFromErr: fmt.Errorf("version policy of repo: %s is invalid", r.Name)})
+	}
+
 	helga_errors.HandleErrors(validationErrs)
 
 	return validationErrs
@@ -53,7 +74,14 @@ func (dest *Repo) Sync(src *Repo) error {
 		dest.DecideByVersion = src.DecideByVersion
 	}
 
+	if src.VersionPolicy != nil && dest.VersionPolicy == nil {
+		dest.VersionPolicy = src.VersionPolicy
+	}
+
 	syncPathsList(&dest.Paths, &src.Paths)
+	syncPathsList(&dest.ExcludePaths, &src.ExcludePaths)
+	syncPropertiesMap(&dest.RequiredProperties, src.RequiredProperties)
+	syncPropertiesMap(&dest.ForbiddenProperties, src.ForbiddenProperties)
 
 	return nil
 }
@@ -76,6 +104,19 @@ func syncPathsList(destPaths, srcPaths *[]string) {
 	}
 }
 
+// properties already set on dest take precedence over src
+func syncPropertiesMap(destProps *map[string]string, srcProps map[string]string) {
+	for k, v := range srcProps {
+		if *destProps == nil {
+			*destProps = make(map[string]string)
+		}
+
+		if _, exists := (*destProps)[k]; !exists {
+			(*destProps)[k] = v
+		}
+	}
+}
+
 func (r *Repo) GetAsHelmRepoEntry() *repo.Entry {
 	return &repo.Entry{
 		Name:                  r.Name,
@@ -83,3 +124,101 @@ func (r *Repo) GetAsHelmRepoEntry() *repo.Entry {
 		PassCredentialsAll:    false,
 	}
 }
+
+// artifactory paths have no leading or trailing slash
+func normalizeRepoPath(p string) string {
+	return strings.Trim(p, "/")
+}
+
+// aql "$match" patterns covering a path pattern, every "**" expands to no folder or to "*",
+// which in aql also matches across folders, so the results are narrowed again by matchesPath
+func aqlPathMatches(pattern string) []string {
+	matches := []string{""}
+
+	for _, seg := range strings.Split(normalizeRepoPath(pattern), "/") {
+		var next []string
+
+		for _, m := range matches {
+			if seg == "**" {
+				next = append(next, m, path.Join(m, "*"))
+			} else {
+				next = append(next, path.Join(m, seg))
+			}
+		}
+
+		matches = next
+	}
+
+	// artifactory reports items in the repo root under the path "."
+	seen := make(map[string]struct{})
+	unique := []string{}
+
+	for _, m := range matches {
+		if m == "" {
+			m = "."
+		}
+
+		if _, exists := seen[m]; !exists {
+			seen[m] = struct{}{}
+			unique = append(unique, m)
+		}
+	}
+
+	return unique
+}
+
+// exclude paths are left to matchesPath since an aql "$nmatch" with "*" would also exclude deeper folders
+func (r *Repo) aqlCriteria(pattern string) map[string]any {
+	var pathClauses []any
+	for _, m := range aqlPathMatches(pattern) {
+		pathClauses = append(pathClauses, map[string]any{"path": map[string]string{"$match": m}})
+	}
+
+	clauses := []any{
+		map[string]any{"repo": map[string]string{"$eq": r.Name}},
+		map[string]any{"name": map[string]string{"$match": "*.tgz"}},
+		map[string]any{"$or": pathClauses},
+	}
+
+	for k, v := range r.RequiredProperties {
+		clauses = append(clauses, map[string]any{"@" + k: map[string]string{"$eq": v}})
+	}
+
+	return map[string]any{"$and": clauses}
+}
+
+// forbidden properties are fetched with the items and checked by matchesProperties since
+// aql "$ne" on a property also drops items that do not have the property at all
+func (r *Repo) aqlIncludes() []string {
+	includes := []string{"repo", "path", "name", "modified", "actual_sha1", "@" + chartNameProperty, "@" + chartVersionProperty}
+
+	for k := range r.ForbiddenProperties {
+		includes = append(includes, "@"+k)
+	}
+
+	return includes
+}
+
+func (r *Repo) matchesProperties(pkg ArtifactHelmPackage) bool {
+	for k, v := range r.ForbiddenProperties {
+		if pkg.HasProperty(k, v) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r *Repo) matchesPath(pattern, itemPath string) bool {
+	if !utils.MatchPathGlob(normalizeRepoPath(pattern), itemPath) {
+		return false
+	}
+
+	for _, e := range r.ExcludePaths {
+		if utils.MatchPathGlob(normalizeRepoPath(e), itemPath) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,187 @@
+package models
+
+import (
+	"encoding/json"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/fennet82/helga/internal/utils"
+)
+
+func TestAQLPathMatches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"/charts/", []string{"charts"}},
+		{"charts/*/stable", []string{"charts/*/stable"}},
+		{"team-a/**", []string{"team-a", "team-a/*"}},
+		{"team-a/**/charts", []string{"team-a/charts", "team-a/*/charts"}},
+		{"**/stable", []string{"stable", "*/stable"}},
+		{"**/stable/**", []string{"stable", "stable/*", "*/stable", "*/stable/*"}},
+		{"**", []string{".", "*"}},
+	}
+
+	for _, tt := range tests {
+		if got := aqlPathMatches(tt.pattern); !slices.Equal(got, tt.want) {
+			t.Errorf("aqlPathMatches(%q) = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+// aql "$match" semantics, "*" matches any characters including "/" and "?" a single one
+func aqlMatch(pattern, itemPath string) bool {
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.ReplaceAll(expr, `\*`, ".*")
+	expr = strings.ReplaceAll(expr, `\?`, ".")
+
+	return regexp.MustCompile("^" + expr + "$").MatchString(itemPath)
+}
+
+// items the client side glob keeps have to be returned by aql in the first place
+func TestAQLPathMatchesCoverPathGlob(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		itemPath string
+	}{
+		{"**/stable", "stable"},
+		{"**/stable", "team-a/web/stable"},
+		{"a/**/c", "a/c"},
+		{"a/**/c", "a/b/c"},
+		{"a/**/c", "a/b/d/c"},
+		{"a/**", "a"},
+		{"a/**/b/**", "a/b"},
+		{"a/*/c", "a/b/c"},
+		{"**", "."},
+	}
+
+	for _, tt := range tests {
+		if !utils.MatchPathGlob(tt.pattern, tt.itemPath) {
+			t.Fatalf("MatchPathGlob(%q, %q) is false, the case does not test coverage", tt.pattern, tt.itemPath)
+		}
+
+		if !slices.ContainsFunc(aqlPathMatches(tt.pattern), func(m string) bool { return aqlMatch(m, tt.itemPath) }) {
+			t.Errorf("aqlPathMatches(%q) = %v, does not cover %q", tt.pattern, aqlPathMatches(tt.pattern), tt.itemPath)
+		}
+	}
+}
+
+// excludes only narrow the results client side, so an item the exclude glob does not match is kept
+func TestRepoExcludePathsKeepDeeperFolders(t *testing.T) {
+	r := &Repo{Name: "helm", Paths: []string{"a/**"}, ExcludePaths: []string{"a/*"}}
+
+	criteria, err := json.Marshal(r.aqlCriteria("a/**"))
+	if err != nil {
+		t.Fatalf("marshaling criteria: %v", err)
+	}
+
+	if strings.Contains(string(criteria), "$nmatch") {
+		t.Errorf("expected no server side excludes, got: %s", criteria)
+	}
+
+	tests := []struct {
+		itemPath string
+		want     bool
+	}{
+		{"a", true},
+		{"a/b", false},
+		{"a/b/c", true},
+	}
+
+	for _, tt := range tests {
+		if got := r.matchesPath("a/**", tt.itemPath); got != tt.want {
+			t.Errorf("matchesPath(%q, %q) = %t, want %t", "a/**", tt.itemPath, got, tt.want)
+		}
+	}
+}
+
+func TestRepoAQLCriteria(t *testing.T) {
+	r := &Repo{Name: "helm", Paths: []string{"team-a/**"}, ExcludePaths: []string{"team-a/legacy/**"}}
+
+	criteria, err := json.Marshal(r.aqlCriteria("team-a/**"))
+	if err != nil {
+		t.Fatalf("marshaling criteria: %v", err)
+	}
+
+	want := `{"$and":[` +
+		`{"repo":{"$eq":"helm"}},` +
+		`{"name":{"$match":"*.tgz"}},` +
+		`{"$or":[{"path":{"$match":"team-a"}},{"path":{"$match":"team-a/*"}}]}]}`
+
+	if string(criteria) != want {
+		t.Errorf("unexpected aql criteria\n got: %s\nwant: %s", criteria, want)
+	}
+}
+
+func TestRepoMatchesPath(t *testing.T) {
+	r := &Repo{Name: "helm", ExcludePaths: []string{"team-a/legacy/**", "/team-a/*/old/"}}
+
+	tests := []struct {
+		pattern  string
+		itemPath string
+		want     bool
+	}{
+		{"team-a/**", "team-a", true},
+		{"team-a/**", "team-a/web", true},
+		{"team-a/**", "team-a/legacy", false},
+		{"team-a/**", "team-a/legacy/web", false},
+		{"team-a/**", "team-a/web/old", false},
+		{"team-a/**", "team-a/web/old/v1", true},
+		{"/charts/*/stable/", "charts/web/stable", true},
+		// aql "$match" with "*" also matches across folders, those items are dropped here
+		{"charts/*/stable", "charts/web/nested/stable", false},
+	}
+
+	for _, tt := range tests {
+		if got := r.matchesPath(tt.pattern, tt.itemPath); got != tt.want {
+			t.Errorf("matchesPath(%q, %q) = %t, want %t", tt.pattern, tt.itemPath, got, tt.want)
+		}
+	}
+}
+
+func TestRepoPropertyFilters(t *testing.T) {
+	r := &Repo{
+		Name:                "helm",
+		RequiredProperties:  map[string]string{"env.approved": "prod"},
+		ForbiddenProperties: map[string]string{"qa.status": "failed"},
+	}
+
+	criteria, err := json.Marshal(r.aqlCriteria("charts"))
+	if err != nil {
+		t.Fatalf("marshaling criteria: %v", err)
+	}
+
+	want := `{"$and":[` +
+		`{"repo":{"$eq":"helm"}},` +
+		`{"name":{"$match":"*.tgz"}},` +
+		`{"$or":[{"path":{"$match":"charts"}}]},` +
+		`{"@env.approved":{"$eq":"prod"}}]}`
+
+	if string(criteria) != want {
+		t.Errorf("unexpected aql criteria\n got: %s\nwant: %s", criteria, want)
+	}
+
+	// forbidden properties are checked client side and need to be fetched with the items
+	if includes := r.aqlIncludes(); !slices.Contains(includes, "@qa.status") {
+		t.Errorf("expected forbidden property in aql includes, got: %v", includes)
+	}
+
+	tests := []struct {
+		name       string
+		properties []ArtifactProperty
+		want       bool
+	}{
+		{"without properties", nil, true},
+		{"forbidden value", []ArtifactProperty{{Key: "qa.status", Value: "failed"}}, false},
+		{"other value", []ArtifactProperty{{Key: "qa.status", Value: "passed"}}, true},
+		{"forbidden among others", []ArtifactProperty{{Key: "env.approved", Value: "prod"}, {Key: "qa.status", Value: "failed"}}, false},
+	}
+
+	for _, tt := range tests {
+		if got := r.matchesProperties(ArtifactHelmPackage{Properties: tt.properties}); got != tt.want {
+			t.Errorf("%s: matchesProperties = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fennet82/helga/internal/utils"
+	"sigs.k8s.io/yaml"
+)
+
+// helm values of one layer, values files are merged in order and inline values on top of them
+type ValuesOverlay struct {
+	Values      map[string]any `yaml:"values,omitempty"`
+	ValuesFiles []string       `yaml:"values_files,omitempty"`
+}
+
+// relative values files are resolved against dir, the directory of the config file declaring them,
+// so they don't depend on the working directory helga is started from
+func (vo *ValuesOverlay) ResolveValuesFiles(dir string) {
+	for i, f := range vo.ValuesFiles {
+		if !filepath.IsAbs(f) {
+			vo.ValuesFiles[i] = filepath.Join(dir, f)
+		}
+	}
+}
+
+func readValuesFile(valuesFilePath string) (map[string]any, error) {
+	data, err := os.ReadFile(valuesFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read values file: %s, err: %w", valuesFilePath, err)
+	}
+
+	values := make(map[string]any)
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("couldn't parse values file: %s, err: %w", valuesFilePath, err)
+	}
+
+	return values, nil
+}
+
+func (vo *ValuesOverlay) mergeInto(dst map[string]any) (map[string]any, error) {
+	if vo == nil {
+		return dst, nil
+	}
+
+	for _, f := range vo.ValuesFiles {
+		values, err := readValuesFile(f)
+		if err != nil {
+			return nil, err
+		}
+
+		dst = utils.MergeMaps(dst, values)
+	}
+
+	return utils.MergeMaps(dst, utils.NormalizeYAMLMap(vo.Values)), nil
+}
+
+// companion values file names may contain {chart} and {version} placeholders
+func companionValuesFileName(pattern string, chart HelmChart) string {
+	return strings.NewReplacer("{chart}", chart.Name(), "{version}", chart.Version()).Replace(pattern)
+}
+
+// fetches the companion values files published next to the chart, files missing in the source are skipped
+func fetchCompanionValues(ctx context.Context, pkg SourcedChart, patterns []string) (*ValuesOverlay, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+
+	vfs, ok := pkg.Source.(ValuesFileSource)
+	if !ok {
+		return nil, nil
+	}
+
+	values := make(map[string]any)
+
+	for _, pattern := range patterns {
+		fileName := companionValuesFileName(pattern, pkg.HelmChart)
+
+		data, found, err := vfs.FetchValuesFile(ctx, pkg.HelmChart, fileName)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't fetch companion values file: %s of chart: %s, err: %w", fileName, pkg.Name(), err)
+		}
+
+		if !found {
+			continue
+		}
+
+		fileValues := make(map[string]any)
+		if err := yaml.Unmarshal(data, &fileValues); err != nil {
+			return nil, fmt.Errorf("couldn't parse companion values file: %s of chart: %s, err: %w", fileName, pkg.Name(), err)
+		}
+
+		values = utils.MergeMaps(values, fileValues)
+	}
+
+	return &ValuesOverlay{Values: values}, nil
+}
+
+// short enough to be used as a label value
+func valuesChecksum(valuesYaml []byte) string {
+	sum := sha256.Sum256(valuesYaml)
+
+	return hex.EncodeToString(sum[:16])
+}
+
+// merges the layers from the least to the most specific one
+func mergeValuesOverlays(layers ...*ValuesOverlay) (map[string]any, error) {
+	values := make(map[string]any)
+
+	for _, l := range layers {
+		var err error
+
+		values, err = l.mergeInto(values)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return values, nil
+}
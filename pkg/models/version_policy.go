@@ -0,0 +1,106 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/fennet82/helga/internal/logger"
+	helga_errors "github.com/fennet82/helga/pkg/errors"
+)
+
+const (
+	PrereleasesInclude = "include"
+	PrereleasesExclude = "exclude"
+
+	NonSemverFallbackTime = "time"
+	NonSemverFallbackSkip = "skip"
+)
+
+// decides which chart versions are candidates, set on a namespace or on a repo which takes precedence
+type VersionPolicy struct {
+	Prereleases        string   `yaml:"prereleases"`         // include (default) or exclude
+	PrereleaseChannels []string `yaml:"prerelease_channels"` // e.g. alpha, rc. every channel is allowed if empty
+	NonSemverFallback  string   `yaml:"non_semver_fallback"` // time (default) compares non semver versions by time, skip drops them
+}
+
+func (vp *VersionPolicy) String() string {
+	return fmt.Sprintf("prereleases: %s, channels: %v, non semver fallback: %s", vp.prereleases(), vp.PrereleaseChannels, vp.nonSemverFallback())
+}
+
+func (vp *VersionPolicy) Validate() []error {
+	logger.GetLoggerInstance().Info(fmt.Sprintf("starting validation for version policy: %s", vp.String()))
+
+	var (
+		validationErrs []error
+		structName     = "VersionPolicy"
+	)
+
+	if !slices.Contains([]string{"", PrereleasesInclude, PrereleasesExclude}, vp.Prereleases) {
+		validationErrs = append(validationErrs, helga_errors.ErrValidation{StructName: structName, DerivedFromErr: fmt.Errorf(
+			"prereleases: %s, needs to be one of: %s, %s", vp.Prereleases, PrereleasesInclude, PrereleasesExclude,
+		)})
+	}
+
+	if !slices.Contains([]string{"", NonSemverFallbackTime, NonSemverFallbackSkip}, vp.NonSemverFallback) {
+		validationErrs = append(validationErrs, helga_errors.ErrValidation{StructName: structName, DerivedFromErr: fmt.Errorf(
+			"non_semver_fallback: %s, needs to be one of: %s, %s", vp.NonSemverFallback, NonSemverFallbackTime, NonSemverFallbackSkip,
+		)})
+	}
+
+	helga_errors.HandleErrors(validationErrs)
+
+	return validationErrs
+}
+
+func (vp *VersionPolicy) prereleases() string {
+	if vp.Prereleases == "" {
+		return PrereleasesInclude
+	}
+
+	return vp.Prereleases
+}
+
+func (vp *VersionPolicy) nonSemverFallback() string {
+	if vp.NonSemverFallback == "" {
+		return NonSemverFallbackTime
+	}
+
+	return vp.NonSemverFallback
+}
+
+// the channel of "rc.1" or "rc1" is "rc"
+func prereleaseChannel(prerelease string) string {
+	return strings.TrimRightFunc(strings.SplitN(prerelease, ".", 2)[0], func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+}
+
+// reports whether the chart version is allowed by the policy and the reason when it is not
+func (vp *VersionPolicy) Allows(chart HelmChart) (bool, string) {
+	v, err := semver.NewVersion(chart.Version())
+	if err != nil {
+		if vp.nonSemverFallback() == NonSemverFallbackSkip {
+			return false, fmt.Sprintf("version: %s is not valid semver and non_semver_fallback is %s", chart.Version(), NonSemverFallbackSkip)
+		}
+
+		return true, ""
+	}
+
+	if v.Prerelease() == "" {
+		return true, ""
+	}
+
+	if vp.prereleases() == PrereleasesExclude {
+		return false, fmt.Sprintf("version: %s is a prerelease and prereleases are excluded", chart.Version())
+	}
+
+	channel := prereleaseChannel(v.Prerelease())
+	if len(vp.PrereleaseChannels) > 0 && !slices.Contains(vp.PrereleaseChannels, channel) {
+		return false, fmt.Sprintf("version: %s is on prerelease channel: %s which is not one of: %v", chart.Version(), channel, vp.PrereleaseChannels)
+	}
+
+	return true, ""
+}
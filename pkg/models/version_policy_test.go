@@ -0,0 +1,102 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+func testLocalPkg(version string, modified time.Time) LocalHelmPackage {
+	return LocalHelmPackage{Metadata: &chart.Metadata{Name: "webapp", Version: version}, TimeModified: modified}
+}
+
+func TestVersionPolicyAllows(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  VersionPolicy
+		version string
+		want    bool
+	}{
+		{"release by default", VersionPolicy{}, "1.2.3", true},
+		{"prerelease by default", VersionPolicy{}, "1.2.3-alpha.1", true},
+		{"non semver falls back to time by default", VersionPolicy{}, "nightly", true},
+		{"release with prereleases excluded", VersionPolicy{Prereleases: PrereleasesExclude}, "1.2.3", true},
+		{"prerelease excluded", VersionPolicy{Prereleases: PrereleasesExclude}, "1.2.3-rc.1", false},
+		{"build metadata is no prerelease", VersionPolicy{Prereleases: PrereleasesExclude}, "1.2.3+build.7", true},
+		{"allowed channel", VersionPolicy{PrereleaseChannels: []string{"rc"}}, "1.2.3-rc.1", true},
+		{"allowed channel without separator", VersionPolicy{PrereleaseChannels: []string{"rc"}}, "1.2.3-rc1", true},
+		{"other channel", VersionPolicy{PrereleaseChannels: []string{"rc"}}, "1.2.3-alpha.1", false},
+		{"channels ignored for releases", VersionPolicy{PrereleaseChannels: []string{"rc"}}, "1.2.3", true},
+		{"non semver skipped", VersionPolicy{NonSemverFallback: NonSemverFallbackSkip}, "nightly", false},
+	}
+
+	for _, tt := range tests {
+		allowed, reason := tt.policy.Allows(testLocalPkg(tt.version, time.Time{}))
+		if allowed != tt.want {
+			t.Errorf("%s: Allows(%s) = %t, want %t", tt.name, tt.version, allowed, tt.want)
+		}
+
+		if !allowed && reason == "" {
+			t.Errorf("%s: expected a reason for rejecting version: %s", tt.name, tt.version)
+		}
+	}
+}
+
+func TestVersionPolicyValidate(t *testing.T) {
+	tests := []struct {
+		policy   VersionPolicy
+		wantErrs int
+	}{
+		{VersionPolicy{}, 0},
+		{VersionPolicy{Prereleases: PrereleasesExclude, NonSemverFallback: NonSemverFallbackSkip}, 0},
+		{VersionPolicy{Prereleases: "sometimes"}, 1},
+		{VersionPolicy{Prereleases: "sometimes", NonSemverFallback: "guess"}, 2},
+	}
+
+	for _, tt := range tests {
+		if errs := tt.policy.Validate(); len(errs) != tt.wantErrs {
+			t.Errorf("Validate(%+v) returned %d errors, want %d", tt.policy, len(errs), tt.wantErrs)
+		}
+	}
+}
+
+func TestDetermineNewerPkgWithReason(t *testing.T) {
+	older := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+
+	tests := []struct {
+		name            string
+		a, b            LocalHelmPackage
+		decideByVersion bool
+		wantVersion     string
+		wantReason      string
+	}{
+		{"by time", testLocalPkg("2.0.0", older), testLocalPkg("1.0.0", newer), false, "1.0.0", SelectionReasonTime},
+		{"by version", testLocalPkg("2.0.0", older), testLocalPkg("1.0.0", newer), true, "2.0.0", SelectionReasonVersion},
+		{"numeric not lexical", testLocalPkg("1.10.0", older), testLocalPkg("1.9.0", newer), true, "1.10.0", SelectionReasonVersion},
+		{"release over its prerelease", testLocalPkg("1.2.0-rc.2", newer), testLocalPkg("1.2.0", older), true, "1.2.0", SelectionReasonVersion},
+		{"prerelease ordering", testLocalPkg("1.2.0-rc.10", older), testLocalPkg("1.2.0-rc.9", newer), true, "1.2.0-rc.10", SelectionReasonVersion},
+		{"build metadata is equal", testLocalPkg("1.2.0+1", older), testLocalPkg("1.2.0+2", newer), true, "1.2.0+2", SelectionReasonEqualVersions},
+		{"non semver falls back to time", testLocalPkg("nightly", newer), testLocalPkg("1.2.0", older), true, "nightly", SelectionReasonNonSemverFallback},
+	}
+
+	for _, tt := range tests {
+		pkg, reason, err := DetermineNewerPkgWithReason(tt.a, tt.b, tt.decideByVersion)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+
+		if pkg.Version() != tt.wantVersion || reason != tt.wantReason {
+			t.Errorf("%s: picked %s by %q, want %s by %q", tt.name, pkg.Version(), reason, tt.wantVersion, tt.wantReason)
+		}
+	}
+}
+
+func TestDetermineNewerPkgRejectsDifferentCharts(t *testing.T) {
+	other := LocalHelmPackage{Metadata: &chart.Metadata{Name: "api", Version: "1.0.0"}}
+
+	if _, err := DetermineNewerPkg(testLocalPkg("1.0.0", time.Time{}), other, true); err == nil {
+		t.Fatal("expected an error comparing pkgs of different charts")
+	}
+}